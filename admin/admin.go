@@ -0,0 +1,328 @@
+// Package admin 提供查看和修改配置的http.Handler, 用于挂载到调试端口:
+//
+//	http.Handle("/debug/vade/", http.StripPrefix("/debug/vade", admin.New(mgr)))
+//
+// 接口:
+//
+//	GET    /keys?prefix=db    配置以及来源的source和path
+//	GET    /keys/{key}        单个配置, 和/keys一样返回没有变量替换的值
+//	GET    /sources           source, path以及状态
+//	GET    /watchers          当前的监听者
+//	GET    /events?limit=20   最近的配置变更, 需要Manager开启WithHistory
+//	PUT    /overrides/{key}   设置覆盖配置, body为JSON格式的值, 不是合法的JSON时作为字符串,
+//	                          只支持标量, 对象和数组需要按子key分别设置
+//	DELETE /overrides/{key}   删除覆盖配置, SetDefault设置的默认值保留
+//
+// 修改覆盖配置需要通过WithAuthorizer授权, 默认拒绝。
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	pkgerrs "github.com/pkg/errors"
+
+	vade "github.com/derry6/vade-go"
+)
+
+const (
+	// Redacted 脱敏后展示的值
+	Redacted = "******"
+
+	// DefaultEventLimit 默认返回的变更数量
+	DefaultEventLimit = 20
+
+	maxBodySize = 1 << 20
+)
+
+// 疑似包含敏感信息的key
+var secretPattern = regexp.MustCompile(`(?i)(password|passwd|secret|token|credential|private[-_.]?key|api[-_.]?key|access[-_.]?key)`)
+
+// Authorizer 校验修改覆盖配置的请求, 返回错误时拒绝
+type Authorizer func(r *http.Request) error
+
+// Redactor 返回用于展示的值
+type Redactor func(key string, value interface{}) interface{}
+
+// RedactSecrets 默认的Redactor, key包含password, secret, token等时隐藏值
+func RedactSecrets(key string, value interface{}) interface{} {
+	if value == nil || !secretPattern.MatchString(key) {
+		return value
+	}
+	return Redacted
+}
+
+// Key 配置以及来源
+type Key struct {
+	Key    string      `json:"key"`
+	Value  interface{} `json:"value"`
+	Source string      `json:"source,omitempty"`
+	Path   string      `json:"path,omitempty"`
+}
+
+// Source source以及已添加的path
+type Source struct {
+	Name     string `json:"name"`
+	Priority int    `json:"priority"`
+	Paths    []Path `json:"paths,omitempty"`
+}
+
+// Path path的版本和状态, 从快照加载时状态为degraded
+type Path struct {
+	Path    string `json:"path"`
+	Version string `json:"version,omitempty"`
+	Format  string `json:"format,omitempty"`
+	Status  string `json:"status"`
+}
+
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded"
+)
+
+type pathLister interface {
+	Paths() []string
+	PathVersion(path string) (version string, format string, ok bool)
+}
+
+// Option Handler的选项
+type Option func(h *handler)
+
+// WithAuthorizer 设置修改覆盖配置的授权函数, 未设置时拒绝所有修改
+func WithAuthorizer(fn Authorizer) Option {
+	return func(h *handler) {
+		h.authorize = fn
+	}
+}
+
+// WithRedactor 替换默认的脱敏函数
+func WithRedactor(fn Redactor) Option {
+	return func(h *handler) {
+		if fn != nil {
+			h.redact = fn
+		}
+	}
+}
+
+type handler struct {
+	mgr       vade.Manager
+	authorize Authorizer
+	redact    Redactor
+	mux       *http.ServeMux
+}
+
+// New 创建mgr的管理接口
+func New(mgr vade.Manager, opts ...Option) http.Handler {
+	h := &handler{mgr: mgr, redact: RedactSecrets, mux: http.NewServeMux()}
+	for _, opt := range opts {
+		opt(h)
+	}
+	h.mux.HandleFunc("/keys", h.onlyGet(h.keys))
+	h.mux.HandleFunc("/keys/", h.onlyGet(h.key))
+	h.mux.HandleFunc("/sources", h.onlyGet(h.sources))
+	h.mux.HandleFunc("/watchers", h.onlyGet(h.watchers))
+	h.mux.HandleFunc("/events", h.onlyGet(h.events))
+	h.mux.HandleFunc("/overrides/", h.overrides)
+	return h
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *handler) onlyGet(fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeError(w, http.StatusMethodNotAllowed, pkgerrs.Errorf("method %s not allowed", r.Method))
+			return
+		}
+		fn(w, r)
+	}
+}
+
+func (h *handler) keyOf(key string, value interface{}) Key {
+	k := Key{Key: key, Value: h.redact(key, value)}
+	k.Source, k.Path, _ = h.mgr.Origin(key)
+	return k
+}
+
+// underPrefix key是否为prefix或者prefix的子key
+func underPrefix(key, prefix string) bool {
+	if prefix == "" || key == prefix {
+		return true
+	}
+	return strings.HasPrefix(key, prefix) && (key[len(prefix)] == '.' || key[len(prefix)] == '[')
+}
+
+func (h *handler) keys(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	keys := []Key{}
+	for k, v := range h.mgr.All() {
+		if underPrefix(k, prefix) {
+			keys = append(keys, h.keyOf(k, v))
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Key < keys[j].Key })
+	writeJSON(w, http.StatusOK, keys)
+}
+
+// rawValue 返回没有变量替换的值, 避免${...}引用的敏感配置绕过脱敏
+func (h *handler) rawValue(key string) (v interface{}, ok bool) {
+	v, ok = h.mgr.All()[key]
+	return
+}
+
+func (h *handler) key(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/keys/")
+	v, ok := h.rawValue(key)
+	if !ok {
+		writeError(w, http.StatusNotFound, pkgerrs.Errorf("key %q not found", key))
+		return
+	}
+	writeJSON(w, http.StatusOK, h.keyOf(key, v))
+}
+
+func (h *handler) sources(w http.ResponseWriter, r *http.Request) {
+	degraded := map[string][]string{}
+	for _, p := range h.mgr.Degraded() {
+		if i := strings.Index(p, ":"); i > 0 {
+			degraded[p[:i]] = append(degraded[p[:i]], p[i+1:])
+		}
+	}
+	sources := []Source{}
+	for _, s := range h.mgr.Sources() {
+		src := Source{Name: s.Name(), Priority: s.Priority()}
+		if l, ok := s.(pathLister); ok {
+			for _, p := range l.Paths() {
+				path := Path{Path: p, Status: StatusOK}
+				path.Version, path.Format, _ = l.PathVersion(p)
+				src.Paths = append(src.Paths, path)
+			}
+		}
+		for _, p := range degraded[s.Name()] {
+			src.Paths = append(src.Paths, Path{Path: p, Status: StatusDegraded})
+		}
+		sources = append(sources, src)
+	}
+	writeJSON(w, http.StatusOK, sources)
+}
+
+func (h *handler) watchers(w http.ResponseWriter, r *http.Request) {
+	watchers := h.mgr.Watchers()
+	if watchers == nil {
+		watchers = []vade.Watcher{}
+	}
+	writeJSON(w, http.StatusOK, watchers)
+}
+
+// events 返回最近的limit次变更, 按版本从旧到新
+func (h *handler) events(w http.ResponseWriter, r *http.Request) {
+	limit := DefaultEventLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, pkgerrs.Errorf("invalid limit %q", s))
+			return
+		}
+		limit = n
+	}
+	sets := h.mgr.History()
+	if len(sets) > limit {
+		sets = sets[len(sets)-limit:]
+	}
+	events := make([]*vade.ChangeSet, 0, len(sets))
+	for _, cs := range sets {
+		// 复制后脱敏, 不修改Manager中的记录
+		c := *cs
+		c.Changes = make([]*vade.Change, 0, len(cs.Changes))
+		for _, change := range cs.Changes {
+			e := *change
+			e.From, e.To = h.redact(e.Key, e.From), h.redact(e.Key, e.To)
+			c.Changes = append(c.Changes, &e)
+		}
+		events = append(events, &c)
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+func (h *handler) overrides(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "PUT, POST, DELETE")
+		writeError(w, http.StatusMethodNotAllowed, pkgerrs.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	if h.authorize == nil {
+		writeError(w, http.StatusForbidden, pkgerrs.New("overrides are disabled"))
+		return
+	}
+	if err := h.authorize(r); err != nil {
+		writeError(w, http.StatusForbidden, err)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, "/overrides/")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, pkgerrs.New("empty key"))
+		return
+	}
+	if r.Method == http.MethodDelete {
+		h.mgr.DeleteOverride(key)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, pkgerrs.Wrap(err, "read body"))
+		return
+	}
+	value := decodeValue(body)
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		// 配置按扁平的key保存, 子key需要分别设置
+		writeError(w, http.StatusBadRequest, pkgerrs.New("value must be a scalar, set sub keys separately"))
+		return
+	}
+	h.mgr.Set(key, value)
+	v, _ := h.rawValue(key)
+	writeJSON(w, http.StatusOK, h.keyOf(key, v))
+}
+
+// decodeValue 按JSON解析值, 整数解析为int, 不是合法的JSON时作为字符串
+func decodeValue(body []byte) interface{} {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		return strings.TrimSpace(string(body))
+	}
+	return fromNumber(v)
+}
+
+func fromNumber(v interface{}) interface{} {
+	if x, ok := v.(json.Number); ok {
+		if n, err := strconv.Atoi(x.String()); err == nil {
+			return n
+		}
+		f, _ := x.Float64()
+		return f
+	}
+	return v
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,122 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	vade "github.com/derry6/vade-go"
+)
+
+type nopHandler struct{}
+
+func (nopHandler) OnPropertyChange(events []*vade.Event) {}
+
+func doRequest(t *testing.T, h http.Handler, method, target, body string, out interface{}) int {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if out != nil {
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), out), w.Body.String())
+	}
+	return w.Code
+}
+
+func TestHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vade")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "app.yaml")
+	assert.NoError(t, ioutil.WriteFile(file, []byte("db:\n  host: localhost\n  password: s3cret\n  dsn: root:${db.password}@tcp(db)\n"), 0644))
+
+	mgr, err := vade.NewManager(vade.WithFileSource([]string{file}, nil), vade.WithHistory(vade.DefaultHistorySize))
+	assert.NoError(t, err)
+	mgr.Watch("^db\\.", nopHandler{})
+	h := New(mgr, WithAuthorizer(func(r *http.Request) error {
+		if r.Header.Get("X-Token") != "admin" {
+			return errors.New("bad token")
+		}
+		return nil
+	}))
+
+	var keys []Key
+	assert.Equal(t, http.StatusOK, doRequest(t, h, "GET", "/keys?prefix=db", "", &keys))
+	assert.Equal(t, []Key{
+		{Key: "db.dsn", Value: "root:${db.password}@tcp(db)", Source: "file", Path: file},
+		{Key: "db.host", Value: "localhost", Source: "file", Path: file},
+		{Key: "db.password", Value: Redacted, Source: "file", Path: file},
+	}, keys)
+
+	// 不做变量替换, 引用的敏感配置不会泄露
+	var key Key
+	assert.Equal(t, http.StatusOK, doRequest(t, h, "GET", "/keys/db.dsn", "", &key))
+	assert.Equal(t, "root:${db.password}@tcp(db)", key.Value)
+
+	var sources []Source
+	assert.Equal(t, http.StatusOK, doRequest(t, h, "GET", "/sources", "", &sources))
+	if assert.Len(t, sources, 1) && assert.Len(t, sources[0].Paths, 1) {
+		assert.Equal(t, file, sources[0].Paths[0].Path)
+		assert.Equal(t, StatusOK, sources[0].Paths[0].Status)
+	}
+
+	var watchers []vade.Watcher
+	assert.Equal(t, http.StatusOK, doRequest(t, h, "GET", "/watchers", "", &watchers))
+	if assert.Len(t, watchers, 1) {
+		assert.Equal(t, "^db\\.", watchers[0].Pattern)
+		assert.Equal(t, "admin.nopHandler", watchers[0].Handler)
+	}
+
+	// 未授权
+	assert.Equal(t, http.StatusForbidden, doRequest(t, h, "PUT", "/overrides/db.port", "3306", nil))
+	assert.Equal(t, http.StatusForbidden, doRequest(t, New(mgr), "PUT", "/overrides/db.port", "3306", nil))
+
+	r := httptest.NewRequest("PUT", "/overrides/db.port", strings.NewReader("3306"))
+	r.Header.Set("X-Token", "admin")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	v, _ := mgr.Get("db.port")
+	assert.Equal(t, 3306, v)
+
+	key = Key{}
+	assert.Equal(t, http.StatusOK, doRequest(t, h, "GET", "/keys/db.port", "", &key))
+	assert.Equal(t, Key{Key: "db.port", Value: float64(3306), Source: "overrides"}, key)
+
+	r = httptest.NewRequest("DELETE", "/overrides/db.port", nil)
+	r.Header.Set("X-Token", "admin")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, http.StatusNotFound, doRequest(t, h, "GET", "/keys/db.port", "", nil))
+
+	var events []*vade.ChangeSet
+	assert.Equal(t, http.StatusOK, doRequest(t, h, "GET", "/events?limit=2", "", &events))
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, vade.Deleted, events[1].Changes[0].Action)
+	}
+	assert.Equal(t, http.StatusMethodNotAllowed, doRequest(t, h, "POST", "/keys", "", nil))
+
+	// 对象和数组不能作为单个key的值
+	r = httptest.NewRequest("PUT", "/overrides/db", strings.NewReader(`{"port": 1}`))
+	r.Header.Set("X-Token", "admin")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 删除覆盖配置后默认值仍然生效
+	mgr.SetDefault("db.timeout", 5)
+	mgr.Set("db.timeout", 10)
+	r = httptest.NewRequest("DELETE", "/overrides/db.timeout", nil)
+	r.Header.Set("X-Token", "admin")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	v, _ = mgr.Get("db.timeout")
+	assert.Equal(t, 5, v)
+}
@@ -0,0 +1,197 @@
+package vade
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/derry6/vade-go/pkg/log"
+	"github.com/derry6/vade-go/source"
+)
+
+// DeprecatedKey 废弃的key, Replacement不为空时作为新key的别名
+type DeprecatedKey struct {
+	Key         string `json:"key"`
+	Message     string `json:"message,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// keyAlias 旧key到新key的映射, 对子key同样生效
+type keyAlias struct {
+	old string
+	new string
+}
+
+// rename 将from及其子key替换为to
+func rename(key, from, to string) (string, bool) {
+	if key == from {
+		return to, true
+	}
+	if strings.HasPrefix(key, from) && (key[len(from)] == '.' || key[len(from)] == '[') {
+		return to + key[len(from):], true
+	}
+	return "", false
+}
+
+// underKey key是否为prefix或者prefix的子key
+func underKey(key, prefix string) bool {
+	_, ok := rename(key, prefix, prefix)
+	return ok
+}
+
+func (mgr *manager) RegisterAlias(oldKey string, newKey string) {
+	oldKey, newKey = mgr.normalizeKey(oldKey), mgr.normalizeKey(newKey)
+	if oldKey == "" || newKey == "" || oldKey == newKey {
+		return
+	}
+	mgr.mutex.Lock()
+	for _, a := range mgr.aliases {
+		if a.old == oldKey && a.new == newKey {
+			mgr.mutex.Unlock()
+			return
+		}
+	}
+	mgr.aliases = append(mgr.aliases, keyAlias{old: oldKey, new: newKey})
+	mgr.mutex.Unlock()
+}
+
+func (mgr *manager) Deprecate(key string, message string, replacement string) {
+	key = mgr.normalizeKey(key)
+	if replacement != "" {
+		mgr.RegisterAlias(key, replacement)
+	}
+	mgr.mutex.Lock()
+	mgr.deprecated[key] = &DeprecatedKey{Key: key, Message: message, Replacement: replacement}
+	mgr.mutex.Unlock()
+	mgr.warnDeprecated(mgr.Keys())
+}
+
+// DeprecatedKeys 返回正在使用的废弃key
+func (mgr *manager) DeprecatedKeys() (keys []DeprecatedKey) {
+	mgr.mutex.RLock()
+	defer mgr.mutex.RUnlock()
+	for _, d := range mgr.deprecated {
+		if mgr.unsafeInUse(d.Key) {
+			keys = append(keys, *d)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Key < keys[j].Key })
+	return keys
+}
+
+func (mgr *manager) unsafeInUse(prefix string) bool {
+	for _, m := range []map[string]interface{}{mgr.overrides, mgr.defaults} {
+		for k := range m {
+			if underKey(k, prefix) {
+				return true
+			}
+		}
+	}
+	for k := range mgr.ksMap {
+		if underKey(k, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// warnDeprecated 每个废弃的key只告警一次
+func (mgr *manager) warnDeprecated(keys []string) {
+	mgr.mutex.Lock()
+	defer mgr.mutex.Unlock()
+	for _, d := range mgr.deprecated {
+		if mgr.warned[d.Key] {
+			continue
+		}
+		for _, k := range keys {
+			if !underKey(mgr.normalizeKey(k), d.Key) {
+				continue
+			}
+			mgr.warned[d.Key] = true
+			if d.Replacement != "" {
+				log.Get().Warnf("Key %q is deprecated, use %q instead: %s", d.Key, d.Replacement, d.Message)
+			} else {
+				log.Get().Warnf("Key %q is deprecated: %s", d.Key, d.Message)
+			}
+			break
+		}
+	}
+}
+
+// unsafeAliasGet 通过别名查找新key的值
+func (mgr *manager) unsafeAliasGet(key string) (val interface{}, ok bool) {
+	for _, a := range mgr.aliases {
+		if old, matched := rename(key, a.new, a.old); matched {
+			if val, ok = mgr.unsafeLookup(old); ok {
+				return val, ok
+			}
+		}
+	}
+	return nil, false
+}
+
+// unsafeAliasKeys 返回旧key对应的新key
+func (mgr *manager) unsafeAliasKeys(key string) (keys []string) {
+	for _, a := range mgr.aliases {
+		if newKey, matched := rename(key, a.old, a.new); matched {
+			keys = append(keys, newKey)
+		}
+	}
+	return
+}
+
+// withAliasEvents 为旧key的事件添加新key的事件, before为事件的key变化前的生效值。
+// 新key的事件根据新key变化前后的生效值生成, 新key本身被设置或者生效值没有变化时忽略。
+func (mgr *manager) withAliasEvents(events []*Event, before map[string]interface{}) []*Event {
+	mgr.mutex.RLock()
+	defer mgr.mutex.RUnlock()
+	if len(mgr.aliases) == 0 {
+		return events
+	}
+	changed := make(map[string]bool, len(events))
+	for _, ev := range events {
+		changed[ev.Key] = true
+	}
+	// lookupBefore 返回key变化前的值
+	lookupBefore := func(key string) (interface{}, bool) {
+		if changed[key] {
+			v, ok := before[key]
+			return v, ok
+		}
+		return mgr.unsafeLookup(key)
+	}
+	all := events
+	added := map[string]bool{}
+	for _, ev := range events {
+		for _, newKey := range mgr.unsafeAliasKeys(ev.Key) {
+			if _, exists := mgr.unsafeConfigured(newKey); exists || added[newKey] {
+				continue
+			}
+			added[newKey] = true
+			from, existed := mgr.defaults[newKey]
+			for _, a := range mgr.aliases {
+				if old, matched := rename(newKey, a.new, a.old); matched {
+					if v, ok := lookupBefore(old); ok {
+						from, existed = v, true
+						break
+					}
+				}
+			}
+			to, exists := mgr.unsafeGet(newKey)
+			var e *Event
+			switch {
+			case !existed && exists:
+				e = source.NewEvent(Created, newKey)
+			case existed && !exists:
+				e = source.NewEvent(Deleted, newKey)
+			case existed && exists && !reflect.DeepEqual(from, to):
+				e = source.NewEvent(Updated, newKey)
+			default:
+				continue
+			}
+			e.Source, e.Path, e.ValueFrom, e.ValueTo = ev.Source, ev.Path, from, to
+			all = append(all, e)
+		}
+	}
+	return all
+}
@@ -0,0 +1,58 @@
+package vade
+
+import (
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestRegisterAlias(t *testing.T) {
+    mgr, err := NewManager()
+    assert.NoError(t, err)
+    mgr.Deprecate("server", "server.* moved to http.*", "http")
+    mgr.RegisterAlias("log_level", "log.level")
+    mgr.SetDefault("http.port", 80)
+
+    h := &testHandler{}
+    mgr.Watch(`^http\.port$`, h)
+    mgr.Set("server.port", 8080)
+    mgr.Set("log_level", "debug")
+
+    v, _ := mgr.Get("http.port")
+    assert.Equal(t, 8080, v)
+    v, _ = mgr.Get("log.level")
+    assert.Equal(t, "debug", v)
+    // 新key的事件根据新key的生效值生成, 默认值被旧key覆盖
+    if assert.Len(t, h.events, 1) {
+        assert.Equal(t, "http.port", h.events[0].Key)
+        assert.Equal(t, Updated, h.events[0].Action)
+        assert.Equal(t, 80, h.events[0].ValueFrom)
+        assert.Equal(t, 8080, h.events[0].ValueTo)
+    }
+
+    var cfg struct {
+        Port int `yaml:"port"`
+    }
+    assert.NoError(t, mgr.Unmarshal(&cfg, WithUnmarshalPrefix("http")))
+    assert.Equal(t, 8080, cfg.Port)
+
+    assert.Equal(t, []DeprecatedKey{{
+        Key: "server", Message: "server.* moved to http.*", Replacement: "http",
+    }}, mgr.DeprecatedKeys())
+
+    // 删除旧key后恢复新key的默认值
+    mgr.Delete("server.port")
+    if assert.Len(t, h.events, 2) {
+        assert.Equal(t, Updated, h.events[1].Action)
+        assert.Equal(t, 8080, h.events[1].ValueFrom)
+        assert.Equal(t, 80, h.events[1].ValueTo)
+    }
+    mgr.Set("server.port", 8080)
+
+    // 新key优先
+    mgr.Set("http.port", 9090)
+    v, _ = mgr.Get("http.port")
+    assert.Equal(t, 9090, v)
+    mgr.Delete("server.port")
+    assert.Empty(t, mgr.DeprecatedKeys())
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	pkgerrs "github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	vade "github.com/derry6/vade-go"
+	"github.com/derry6/vade-go/source"
+	"github.com/derry6/vade-go/source/client"
+	"github.com/derry6/vade-go/source/parser"
+)
+
+// underPrefix key是否为prefix或者prefix的子key
+func underPrefix(key, prefix string) bool {
+	if prefix == "" || key == prefix {
+		return true
+	}
+	return strings.HasPrefix(key, prefix) && (key[len(prefix)] == '.' || key[len(prefix)] == '[')
+}
+
+// resolve 返回prefix下变量替换后的生效配置
+func resolve(mgr vade.Manager, prefix string) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	for _, k := range mgr.Keys() {
+		if !underPrefix(k, prefix) {
+			continue
+		}
+		v, ok := mgr.Get(k)
+		if !ok {
+			return nil, pkgerrs.Errorf("can't resolve key %q", k)
+		}
+		values[k] = v
+	}
+	return leaves(values), nil
+}
+
+func runResolve(fs *pflag.FlagSet, args []string, stdout io.Writer) error {
+	sf := &sourceFlags{}
+	sf.register(fs)
+	output := fs.StringP("output", "o", "yaml", "output format: yaml, json or props")
+	prefix := fs.String("prefix", "", "only print keys under prefix")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return pkgerrs.Errorf("unexpected arguments %v", fs.Args())
+	}
+	mgr, err := sf.manager()
+	if err != nil {
+		return err
+	}
+	values, err := resolve(mgr, *prefix)
+	if err != nil {
+		return err
+	}
+	return encode(stdout, *output, values)
+}
+
+// keyInfo 配置以及来源
+type keyInfo struct {
+	Key    string      `json:"key"`
+	Value  interface{} `json:"value"`
+	Source string      `json:"source,omitempty"`
+	Path   string      `json:"path,omitempty"`
+}
+
+func runGet(fs *pflag.FlagSet, args []string, stdout io.Writer) error {
+	sf := &sourceFlags{}
+	sf.register(fs)
+	output := fs.StringP("output", "o", "text", "output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return pkgerrs.New("missing key")
+	}
+	mgr, err := sf.manager()
+	if err != nil {
+		return err
+	}
+	var infos []keyInfo
+	for _, key := range fs.Args() {
+		if sf.relaxed {
+			key = source.RelaxedKey(key)
+		}
+		// key不是叶子节点时输出所有的子key
+		values, err := resolve(mgr, key)
+		if err != nil {
+			return err
+		}
+		if len(values) == 0 {
+			return pkgerrs.Errorf("key %q not found", key)
+		}
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			info := keyInfo{Key: k, Value: values[k]}
+			info.Source, info.Path, _ = mgr.Origin(k)
+			infos = append(infos, info)
+		}
+	}
+	switch *output {
+	case "json":
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(infos)
+	case "text":
+		tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE\tPATH")
+		for _, info := range infos {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", info.Key, formatValue(info.Value), info.Source, info.Path)
+		}
+		return tw.Flush()
+	}
+	return pkgerrs.Errorf("unknown output format %q, expect text or json", *output)
+}
+
+func runDiff(fs *pflag.FlagSet, args []string, stdout io.Writer) error {
+	configs := &clientConfigs{}
+	configs.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return pkgerrs.New("diff requires two sources")
+	}
+	from, err := loadSpec(fs.Arg(0), configs)
+	if err != nil {
+		return err
+	}
+	to, err := loadSpec(fs.Arg(1), configs)
+	if err != nil {
+		return err
+	}
+	changes := vade.DiffValues(from, to)
+	for _, c := range changes {
+		switch c.Action {
+		case vade.Created:
+			_, _ = fmt.Fprintf(stdout, "+ %s = %s\n", c.Key, formatValue(c.To))
+		case vade.Deleted:
+			_, _ = fmt.Fprintf(stdout, "- %s = %s\n", c.Key, formatValue(c.From))
+		default:
+			_, _ = fmt.Fprintf(stdout, "~ %s = %s -> %s\n", c.Key, formatValue(c.From), formatValue(c.To))
+		}
+	}
+	if len(changes) > 0 {
+		return errDiffer
+	}
+	return nil
+}
+
+// parserFor 选择检查文件使用的parser, 依次使用指定的格式, 目标path和文件的扩展名
+func parserFor(format, path, file string) parser.Parser {
+	if format != "" {
+		p, _ := parser.Get(format)
+		return p
+	}
+	if p := parser.ForPath(path); p != nil {
+		return p
+	}
+	return parser.ForPath(file)
+}
+
+func runPush(fs *pflag.FlagSet, args []string, stdout io.Writer) error {
+	configs := &clientConfigs{}
+	configs.register(fs)
+	format := fs.String("format", "", "format of the file, default by the extension of path")
+	timeout := fs.Duration("timeout", 10*time.Second, "push timeout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 || !clientSpec.MatchString(fs.Arg(0)) {
+		fs.Usage()
+		return pkgerrs.New("push requires client:path and file")
+	}
+	name, path := splitSpec(fs.Arg(0))
+	if name == client.File || name == client.Env || name == client.Flag {
+		// 本地的客户端不支持发布
+		return pkgerrs.Errorf("client %q does not support push", name)
+	}
+	data, err := ioutil.ReadFile(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	// 使用服务相同的parser检查, 避免发布无法解析的配置
+	p := parserFor(*format, path, fs.Arg(1))
+	if p == nil {
+		return pkgerrs.Errorf("unknown format of %q, use --format", fs.Arg(1))
+	}
+	values, err := p.Parse(data, "")
+	if err != nil {
+		return pkgerrs.Wrapf(err, "invalid config %q", fs.Arg(1))
+	}
+	cfg, err := configs.get(name)
+	if err != nil {
+		return err
+	}
+	c, err := client.New(name, cfg)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	if err = c.Push(ctx, path, data); err != nil {
+		return pkgerrs.Wrapf(err, "push to %s", fs.Arg(0))
+	}
+	_, _ = fmt.Fprintf(stdout, "pushed %d keys to %s\n", len(leaves(values)), fs.Arg(0))
+	return nil
+}
@@ -0,0 +1,98 @@
+// vade 调试配置的命令行工具, 使用和服务相同的client, source和parser解析配置:
+//
+//	vade resolve --file service.yaml --env --nacos app.yaml --config nacos=nacos.yaml -o json
+//	vade get --file service.yaml db.host
+//	vade diff service.yaml nacos:app.yaml --config nacos=nacos.yaml
+//	vade push --config nacos=nacos.yaml nacos:app.yaml service.yaml
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	pkgerrs "github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/derry6/vade-go/source/client"
+	_ "github.com/derry6/vade-go/source/client/apollo"
+	_ "github.com/derry6/vade-go/source/client/configmap"
+	"github.com/derry6/vade-go/source/client/consul"
+	_ "github.com/derry6/vade-go/source/client/etcd"
+	_ "github.com/derry6/vade-go/source/client/nacos"
+	"github.com/derry6/vade-go/source/parser"
+)
+
+const usage = `vade is a tool for debugging configurations.
+
+Usage:
+	vade <command> [flags] [args]
+
+Commands:
+	resolve   print the effective configurations
+	get       print the value and origin of keys
+	diff      compare two config sources as flattened keys
+	push      publish a config file through a client
+
+Run "vade <command> -h" for the flags of a command.
+`
+
+// errDiffer diff发现差异, 只设置退出码
+var errDiffer = pkgerrs.New("configurations differ")
+
+type command struct {
+	name  string
+	usage string
+	run   func(fs *pflag.FlagSet, args []string, stdout io.Writer) error
+}
+
+var commands = []*command{
+	{"resolve", "vade resolve [source flags] [-o yaml|json|props]", runResolve},
+	{"get", "vade get [source flags] <key>...", runGet},
+	{"diff", "vade diff [--config client=file] <[client:]path> <[client:]path>", runDiff},
+	{"push", "vade push [--config client=file] [--format ext] <client:path> <file>", runPush},
+}
+
+func init() {
+	// consul客户端没有自动注册
+	_ = client.RegisterClient(consul.Name, consul.NewClient)
+	// 保留空的map和列表, 输出为{}和[]
+	parser.Register("yaml", parser.NewYAML(parser.WithEmpty()))
+	parser.Register("yml", parser.NewYAML(parser.WithEmpty()))
+	parser.Register("json", parser.NewJSON(parser.WithEmpty()))
+	parser.Register("toml", parser.NewTOML(parser.WithEmpty()))
+	parser.Register("hcl", parser.NewHCL(parser.WithEmpty()))
+	parser.Register("tf", parser.NewHCL(parser.WithEmpty()))
+}
+
+func run(args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		_, _ = fmt.Fprint(stderr, usage)
+		return nil
+	}
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		fs := pflag.NewFlagSet(cmd.name, pflag.ContinueOnError)
+		fs.SetOutput(stderr)
+		fs.Usage = func() {
+			_, _ = fmt.Fprintf(stderr, "Usage:\n\t%s\n\nFlags:\n%s", cmd.usage, fs.FlagUsages())
+		}
+		err := cmd.run(fs, args[1:], stdout)
+		if err == pflag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+	return pkgerrs.Errorf("unknown command %q, run \"vade help\" for usage", args[0])
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if err != errDiffer {
+			_, _ = fmt.Fprintln(os.Stderr, "vade:", err)
+		}
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnflatten(t *testing.T) {
+	tree := unflatten(leaves(map[string]interface{}{
+		"a.b":         1,
+		"a.list":      2,
+		"a.list[0]":   "x",
+		"a.list[1].c": true,
+	}))
+	assert.Equal(t, map[string]interface{}{
+		"a": map[string]interface{}{
+			"b":    1,
+			"list": []interface{}{"x", map[string]interface{}{"c": true}},
+		},
+	}, tree)
+}
+
+func TestLeavesEmpty(t *testing.T) {
+	values := leaves(map[string]interface{}{
+		"a":         []interface{}{},
+		"b":         map[string]interface{}{},
+		"num":       0,
+		"list":      1,
+		"list[0].c": 1,
+	})
+	assert.Equal(t, map[string]interface{}{
+		"a":         []interface{}{},
+		"b":         map[string]interface{}{},
+		"num":       0,
+		"list[0].c": 1,
+	}, values)
+	var out bytes.Buffer
+	assert.NoError(t, encode(&out, "props", values))
+	assert.Equal(t, "a=[]\nb={}\nlist[0].c=1\nnum=0\n", out.String())
+	out.Reset()
+	assert.NoError(t, encode(&out, "yaml", values))
+	assert.Equal(t, "a: []\nb: {}\nlist:\n- c: 1\nnum: 0\n", out.String())
+}
+
+func TestCommands(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vade")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "app.yaml")
+	assert.NoError(t, ioutil.WriteFile(file, []byte("host: localhost\ndb:\n  url: ${host}:3306\n  pool: 10\n"), 0644))
+	props := filepath.Join(dir, "app.properties")
+	assert.NoError(t, ioutil.WriteFile(props, []byte("host=localhost\ndb.url=remote:3306\n"), 0644))
+
+	var out, errOut bytes.Buffer
+	assert.NoError(t, run([]string{"resolve", "-f", file, "-o", "props"}, &out, &errOut))
+	assert.Equal(t, "db.pool=10\ndb.url=localhost\\:3306\nhost=localhost\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, run([]string{"get", "-f", file, "-o", "json", "db.url"}, &out, &errOut))
+	assert.JSONEq(t, `[{"key":"db.url","value":"localhost:3306","source":"file","path":"`+file+`"}]`, out.String())
+	assert.Error(t, run([]string{"get", "-f", file, "missing"}, &out, &errOut))
+
+	out.Reset()
+	assert.Equal(t, errDiffer, run([]string{"diff", file, "file:" + props}, &out, &errOut))
+	assert.Equal(t, "- db.pool = 10\n~ db.url = \"${host}:3306\" -> \"remote:3306\"\n", out.String())
+	assert.NoError(t, run([]string{"diff", file, file}, &out, &errOut))
+
+	assert.Error(t, run([]string{"push", "file:" + props, file}, &out, &errOut))
+	assert.Error(t, run([]string{"unknown"}, &out, &errOut))
+}
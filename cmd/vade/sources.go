@@ -0,0 +1,157 @@
+package main
+
+import (
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+
+	pkgerrs "github.com/pkg/errors"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+
+	vade "github.com/derry6/vade-go"
+	"github.com/derry6/vade-go/source"
+	"github.com/derry6/vade-go/source/client"
+)
+
+// 可以通过--<name> path添加的远程配置源
+var remoteClients = []string{"nacos", "apollo", "etcd", "consul", "configmap", "secret"}
+
+// clientSpec 匹配client:path中的client名字
+var clientSpec = regexp.MustCompile(`^([a-z][a-z0-9_-]+):(.+)$`)
+
+// clientConfigs 通过--config client=file指定的客户端配置
+type clientConfigs struct {
+	specs []string
+}
+
+func (c *clientConfigs) register(fs *pflag.FlagSet) {
+	fs.StringArrayVar(&c.specs, "config", nil, "client config file in yaml, format: client=file")
+}
+
+// get 返回客户端的配置, 命令行工具不监听变化
+func (c *clientConfigs) get(name string) (*client.Config, error) {
+	cfg := client.DefaultConfig()
+	for _, spec := range c.specs {
+		i := strings.Index(spec, "=")
+		if i <= 0 {
+			return nil, pkgerrs.Errorf("invalid client config %q, expect client=file", spec)
+		}
+		if spec[:i] != name {
+			continue
+		}
+		data, err := ioutil.ReadFile(spec[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		if err = yaml.Unmarshal(data, cfg); err != nil {
+			return nil, pkgerrs.Wrapf(err, "parse config of client %q", name)
+		}
+	}
+	cfg.WatchDisabled = true
+	return cfg, nil
+}
+
+// sourceFlags 组成Manager的配置源, 和服务中的vade.Option对应
+type sourceFlags struct {
+	files     []string
+	optionals []string
+	dirs      []string
+	env       bool
+	envPrefix string
+	dotenvs   []string
+	relaxed   bool
+	remotes   map[string]*[]string
+	configs   clientConfigs
+}
+
+func (sf *sourceFlags) register(fs *pflag.FlagSet) {
+	fs.StringArrayVarP(&sf.files, "file", "f", nil, "required config file")
+	fs.StringArrayVar(&sf.optionals, "optional-file", nil, "optional config file")
+	fs.StringArrayVar(&sf.dirs, "dir", nil, "config directory")
+	fs.BoolVar(&sf.env, "env", false, "add environment variables")
+	fs.StringVar(&sf.envPrefix, "env-prefix", "", "map environment variables with prefix to keys")
+	fs.StringArrayVar(&sf.dotenvs, "dotenv", nil, "dotenv file, implies --env")
+	fs.BoolVar(&sf.relaxed, "relaxed-keys", false, "match keys ignoring case, '-' and '_'")
+	sf.remotes = map[string]*[]string{}
+	for _, name := range remoteClients {
+		sf.remotes[name] = fs.StringArray(name, nil, "required path of "+name+" client")
+	}
+	sf.configs.register(fs)
+}
+
+// manager 按照服务相同的方式创建Manager
+func (sf *sourceFlags) manager() (vade.Manager, error) {
+	var opts []vade.Option
+	if len(sf.files) > 0 || len(sf.optionals) > 0 || len(sf.dirs) > 0 {
+		opts = append(opts, vade.WithFileSource(sf.files, sf.optionals))
+		for _, dir := range sf.dirs {
+			opts = append(opts, vade.WithFileDirectory(dir, true))
+		}
+	}
+	if sf.env || sf.envPrefix != "" || len(sf.dotenvs) > 0 {
+		opts = append(opts, vade.WithEnvSource())
+		if sf.envPrefix != "" {
+			opts = append(opts, vade.WithEnvMapping(vade.WithEnvPrefix(sf.envPrefix), vade.WithEnvMappedKeys()))
+		}
+		if len(sf.dotenvs) > 0 {
+			opts = append(opts, vade.WithDotenvFiles(sf.dotenvs...))
+		}
+	}
+	if sf.relaxed {
+		opts = append(opts, vade.WithRelaxedKeys())
+	}
+	var names []string
+	for name, paths := range sf.remotes {
+		if len(*paths) == 0 {
+			continue
+		}
+		cfg, err := sf.configs.get(name)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, vade.WithRemoteSource(name, cfg))
+		names = append(names, name)
+	}
+	mgr, err := vade.NewManager(opts...)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, path := range *sf.remotes[name] {
+			if err = mgr.AddPath(name, path, source.WithPathRequired()); err != nil {
+				return nil, pkgerrs.Wrapf(err, "add %s:%s", name, path)
+			}
+		}
+	}
+	return mgr, nil
+}
+
+// splitSpec 解析[client:]path, 没有client时为文件
+func splitSpec(spec string) (name string, path string) {
+	if m := clientSpec.FindStringSubmatch(spec); m != nil {
+		return m[1], m[2]
+	}
+	return client.File, spec
+}
+
+// loadSpec 使用单个source读取[client:]path的扁平配置
+func loadSpec(spec string, configs *clientConfigs) (map[string]interface{}, error) {
+	name, path := splitSpec(spec)
+	cfg, err := configs.get(name)
+	if err != nil {
+		return nil, err
+	}
+	c, err := client.New(name, cfg)
+	if err != nil {
+		return nil, err
+	}
+	s := source.New(name, c)
+	defer s.Close()
+	if err = s.AddPath(path, source.WithPathRequired()); err != nil {
+		return nil, pkgerrs.Wrapf(err, "load %s", spec)
+	}
+	return leaves(s.All()), nil
+}
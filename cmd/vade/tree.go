@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	pkgerrs "github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/derry6/vade-go/source/parser"
+)
+
+// leaves 去掉数组的长度key, 只保留叶子节点, 空的map和列表(WithEmpty)作为叶子节点保留
+func leaves(values map[string]interface{}) map[string]interface{} {
+	lists := map[string]bool{}
+	for k := range values {
+		// k的所有父列表, 如a.b[0].c[1]的a.b和a.b[0].c
+		for i := strings.Index(k, "["); i > 0; {
+			lists[k[:i]] = true
+			next := strings.Index(k[i+1:], "[")
+			if next < 0 {
+				break
+			}
+			i += next + 1
+		}
+	}
+	out := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		if _, isLen := v.(int); isLen && lists[k] {
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+type segment struct {
+	name  string
+	index int // 数组下标, 小于0时为name
+}
+
+// splitKey 将a.b[0].c拆分为a, b, 0, c
+func splitKey(key string) (segs []segment) {
+	for _, part := range strings.Split(key, ".") {
+		for {
+			i := strings.Index(part, "[")
+			j := strings.Index(part, "]")
+			if i < 0 || j < i {
+				break
+			}
+			n, err := strconv.Atoi(part[i+1 : j])
+			if err != nil || n < 0 {
+				break
+			}
+			if i > 0 {
+				segs = append(segs, segment{name: part[:i], index: -1})
+			}
+			segs = append(segs, segment{index: n})
+			part = part[j+1:]
+		}
+		if part != "" {
+			segs = append(segs, segment{name: part, index: -1})
+		}
+	}
+	return
+}
+
+func insert(node interface{}, segs []segment, value interface{}) interface{} {
+	if len(segs) == 0 {
+		return value
+	}
+	s := segs[0]
+	if s.index < 0 {
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			m = map[string]interface{}{}
+		}
+		m[s.name] = insert(m[s.name], segs[1:], value)
+		return m
+	}
+	a, _ := node.([]interface{})
+	for len(a) <= s.index {
+		a = append(a, nil)
+	}
+	a[s.index] = insert(a[s.index], segs[1:], value)
+	return a
+}
+
+// unflatten 将扁平的配置还原为多级的map
+func unflatten(values map[string]interface{}) map[string]interface{} {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	// 父节点先设置, 子节点覆盖父节点的值
+	sort.Strings(keys)
+	tree := map[string]interface{}{}
+	for _, k := range keys {
+		tree = insert(tree, splitKey(k), values[k]).(map[string]interface{})
+	}
+	return tree
+}
+
+// scalars properties格式只能输出标量, 空的map和列表输出为{}和[]
+func scalars(values map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+			out[k] = formatValue(v)
+		case nil:
+			out[k] = ""
+		default:
+			out[k] = v
+		}
+	}
+	return out
+}
+
+func encode(w io.Writer, format string, values map[string]interface{}) (err error) {
+	var data []byte
+	switch format {
+	case "yaml", "yml":
+		data, err = yaml.Marshal(unflatten(values))
+	case "json":
+		data, err = json.MarshalIndent(unflatten(values), "", "  ")
+		data = append(data, '\n')
+	case "props", "properties":
+		data, err = parser.MarshalProps(scalars(values))
+	default:
+		return pkgerrs.Errorf("unknown output format %q, expect yaml, json or props", format)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func formatValue(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return strconv.Quote(x)
+	case map[string]interface{}, []interface{}:
+		if data, err := json.Marshal(x); err == nil {
+			return string(data)
+		}
+	}
+	return fmt.Sprint(v)
+}
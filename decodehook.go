@@ -0,0 +1,235 @@
+package vade
+
+import (
+    "math/big"
+    "net"
+    "net/url"
+    "reflect"
+    "regexp"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    pkgerrs "github.com/pkg/errors"
+)
+
+// DecodeHookFunc 在Unmarshal设置字段之前转换数据, from是数据的类型, to是目标类型,
+// 返回的数据继续交给后续的hook, 最终赋值给目标字段
+type DecodeHookFunc func(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error)
+
+// ByteSize 字节数, 支持"64MB", "1.5GiB"等写法, 单位都按1024计算
+type ByteSize int64
+
+type decodeHook struct {
+    from reflect.Type
+    to   reflect.Type
+    fn   DecodeHookFunc
+}
+
+func (h *decodeHook) match(from, to reflect.Type) bool {
+    return (h.from == nil || h.from == from) && (h.to == nil || h.to == to)
+}
+
+var (
+    stringType = reflect.TypeOf("")
+
+    _hooksMu     sync.RWMutex
+    _decodeHooks = []decodeHook{
+        {stringType, reflect.TypeOf(net.IP{}), hookIP},
+        {stringType, reflect.TypeOf(url.URL{}), hookURL},
+        {stringType, reflect.TypeOf(&url.URL{}), hookURL},
+        {stringType, reflect.TypeOf(&regexp.Regexp{}), hookRegexp},
+        {stringType, reflect.TypeOf(big.Int{}), hookBigInt},
+        {stringType, reflect.TypeOf(&big.Int{}), hookBigInt},
+        {stringType, reflect.TypeOf(&time.Location{}), hookLocation},
+        {nil, reflect.TypeOf(ByteSize(0)), hookByteSize},
+    }
+)
+
+// RegisterDecodeHook 注册全局的decode hook, 对所有的Unmarshal生效, from或to为nil时匹配任意类型
+func RegisterDecodeHook(from reflect.Type, to reflect.Type, fn DecodeHookFunc) {
+    _hooksMu.Lock()
+    defer _hooksMu.Unlock()
+    _decodeHooks = append(_decodeHooks, decodeHook{from, to, fn})
+}
+
+// WithDecodeHook 指定本次Unmarshal使用的decode hook, 先于全局的hook执行
+func WithDecodeHook(from reflect.Type, to reflect.Type, fn DecodeHookFunc) UnmarshalOption {
+    return func(opts *decoder) {
+        opts.hooks = append(opts.hooks, decodeHook{from, to, fn})
+    }
+}
+
+// WithEnum 将字符串按names转换为枚举值, names的类型为map[string]T
+func WithEnum(names interface{}) UnmarshalOption {
+    return WithDecodeHook(stringType, reflect.TypeOf(names).Elem(), EnumDecodeHook(names))
+}
+
+// ComposeDecodeHooks 依次执行多个hook, 前一个hook的输出作为后一个的输入
+func ComposeDecodeHooks(fns ...DecodeHookFunc) DecodeHookFunc {
+    return func(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+        var err error
+        for _, fn := range fns {
+            if data, err = fn(from, to, data); err != nil {
+                return nil, err
+            }
+            from = reflect.TypeOf(data)
+        }
+        return data, nil
+    }
+}
+
+// EnumDecodeHook 返回将字符串转换为枚举值的hook, names的类型为map[string]T,
+// 名字先精确匹配, 再忽略大小写匹配
+func EnumDecodeHook(names interface{}) DecodeHookFunc {
+    rv := reflect.ValueOf(names)
+    if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+        panic(pkgerrs.Errorf("enum names must be map[string]T, got %T", names))
+    }
+    return func(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+        s, ok := data.(string)
+        if !ok || to != rv.Type().Elem() {
+            return data, nil
+        }
+        if v := rv.MapIndex(reflect.ValueOf(s).Convert(rv.Type().Key())); v.IsValid() {
+            return v.Interface(), nil
+        }
+        var valid []string
+        for _, k := range rv.MapKeys() {
+            if strings.EqualFold(k.String(), s) {
+                return rv.MapIndex(k).Interface(), nil
+            }
+            valid = append(valid, k.String())
+        }
+        sort.Strings(valid)
+        return nil, pkgerrs.Errorf("invalid %s %q, expect one of %v", to, s, valid)
+    }
+}
+
+func hookIP(_ reflect.Type, _ reflect.Type, data interface{}) (interface{}, error) {
+    ip := net.ParseIP(strings.TrimSpace(data.(string)))
+    if ip == nil {
+        return nil, pkgerrs.Errorf("invalid ip: %q", data)
+    }
+    return ip, nil
+}
+
+func hookURL(_ reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+    u, err := url.Parse(data.(string))
+    if err != nil {
+        return nil, err
+    }
+    if to.Kind() == reflect.Ptr {
+        return u, nil
+    }
+    return *u, nil
+}
+
+func hookRegexp(_ reflect.Type, _ reflect.Type, data interface{}) (interface{}, error) {
+    return regexp.Compile(data.(string))
+}
+
+func hookBigInt(_ reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+    n, ok := new(big.Int).SetString(strings.TrimSpace(data.(string)), 0)
+    if !ok {
+        return nil, pkgerrs.Errorf("invalid big.Int: %q", data)
+    }
+    if to.Kind() == reflect.Ptr {
+        return n, nil
+    }
+    return *n, nil
+}
+
+func hookLocation(_ reflect.Type, _ reflect.Type, data interface{}) (interface{}, error) {
+    return time.LoadLocation(data.(string))
+}
+
+func hookByteSize(_ reflect.Type, _ reflect.Type, data interface{}) (interface{}, error) {
+    n, err := toBytes(data)
+    return ByteSize(n), err
+}
+
+// hooksFor 返回目标类型可能匹配的hook
+func (u *decoder) hooksFor(to reflect.Type) (hooks []decodeHook) {
+    elem := to
+    if to.Kind() == reflect.Ptr {
+        elem = to.Elem()
+    }
+    _hooksMu.RLock()
+    all := append(u.hooks[:len(u.hooks):len(u.hooks)], _decodeHooks...)
+    _hooksMu.RUnlock()
+    for _, h := range all {
+        if h.to == nil || h.to == to || h.to == elem {
+            hooks = append(hooks, h)
+        }
+    }
+    return
+}
+
+func runHooks(hooks []decodeHook, to reflect.Type, data interface{}) (out interface{}, matched bool, err error) {
+    for i := range hooks {
+        from := reflect.TypeOf(data)
+        if !hooks[i].match(from, to) {
+            continue
+        }
+        if data, err = hooks[i].fn(from, to, data); err != nil {
+            return nil, true, err
+        }
+        matched = true
+    }
+    return data, matched, nil
+}
+
+// applyHooks 在按类型解码之前执行decode hook, done为true表示已经完成了设置
+func (u *decoder) applyHooks(key string, out reflect.Value) (done, good bool) {
+    hooks := u.hooksFor(out.Type())
+    if len(hooks) == 0 {
+        return false, false
+    }
+    value, ok := u.getValue(key)
+    if !ok || value == nil {
+        return false, false
+    }
+    target := out
+    data, matched, err := runHooks(hooks, out.Type(), value)
+    if !matched && err == nil && out.Kind() == reflect.Ptr {
+        // *T的字段也可以使用T的hook
+        if data, matched, err = runHooks(hooks, out.Type().Elem(), value); matched && err == nil {
+            if out.IsNil() {
+                out.Set(reflect.New(out.Type().Elem()))
+            }
+            target = out.Elem()
+        }
+    }
+    if err != nil {
+        u.addErr(err, key, out)
+        return true, false
+    }
+    if !matched {
+        return false, false
+    }
+    if data == nil {
+        target.Set(reflect.Zero(target.Type()))
+        return true, true
+    }
+    rv := reflect.ValueOf(data)
+    if rv.Type().AssignableTo(target.Type()) {
+        target.Set(rv)
+        return true, true
+    }
+    switch target.Kind() {
+    case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct, reflect.Ptr:
+        if rv.Type() == reflect.TypeOf(value) {
+            // hook没有转换数据, 按默认的方式解码
+            return false, false
+        }
+        if rv.Type().ConvertibleTo(target.Type()) {
+            target.Set(rv.Convert(target.Type()))
+            return true, true
+        }
+        u.addErr(pkgerrs.Errorf("decode hook returns %T", data), key, out)
+        return true, false
+    }
+    return true, u.setBasic(key, data, target)
+}
@@ -0,0 +1,70 @@
+package vade
+
+import (
+    "net"
+    "net/url"
+    "reflect"
+    "regexp"
+    "strings"
+    "testing"
+)
+
+type testLevel int
+
+const (
+    testLevelInfo testLevel = iota
+    testLevelWarn
+)
+
+func TestUnmarshalDecodeHooks(t *testing.T) {
+    store := newTestUnmarshalGetter()
+    store.Set("ip", "10.0.0.1")
+    store.Set("url", "http://example.com/a")
+    store.Set("re", "^a+$")
+    store.Set("size", "64MB")
+    store.Set("level", "WARN")
+    store.Set("name", " vade ")
+    type Value struct {
+        IP    net.IP         `yaml:"ip"`
+        URL   *url.URL       `yaml:"url"`
+        Re    *regexp.Regexp `yaml:"re"`
+        Size  ByteSize       `yaml:"size"`
+        Level testLevel      `yaml:"level"`
+        Name  string         `yaml:"name"`
+    }
+    trim := func(from, to reflect.Type, data interface{}) (interface{}, error) {
+        return strings.TrimSpace(data.(string)), nil
+    }
+    var v Value
+    err := unmarshal(store.Get, store.Keys(), &v,
+        WithEnum(map[string]testLevel{"info": testLevelInfo, "warn": testLevelWarn}),
+        WithDecodeHook(stringType, stringType, ComposeDecodeHooks(trim, trim)))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !v.IP.Equal(net.IPv4(10, 0, 0, 1)) {
+        t.Errorf("ip = %v", v.IP)
+    }
+    if v.URL == nil || v.URL.Host != "example.com" {
+        t.Errorf("url = %v", v.URL)
+    }
+    if v.Re == nil || !v.Re.MatchString("aaa") {
+        t.Errorf("regexp = %v", v.Re)
+    }
+    if v.Size != 64<<20 {
+        t.Errorf("size = %d", v.Size)
+    }
+    if v.Level != testLevelWarn {
+        t.Errorf("level = %d", v.Level)
+    }
+    if v.Name != "vade" {
+        t.Errorf("name = %q", v.Name)
+    }
+
+    store.Set("level", "debug")
+    err = unmarshal(store.Get, store.Keys(), &v,
+        WithEnum(map[string]testLevel{"info": testLevelInfo, "warn": testLevelWarn}))
+    if _, ok := err.(*TypeError); !ok {
+        t.Errorf("Unmarshal error: %v, expect TypeError", err)
+    }
+}
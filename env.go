@@ -0,0 +1,103 @@
+package vade
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"text/tabwriter"
+
+	pkgerrs "github.com/pkg/errors"
+
+	"github.com/derry6/vade-go/source/client"
+)
+
+// envDefaultPath 环境变量的path
+const envDefaultPath = "default"
+
+// reloader 支持同步重新加载path的source
+type reloader interface {
+	Reload(path string) error
+}
+
+// EnvBinding 环境变量和配置key的绑定
+type EnvBinding struct {
+	Name  string // 环境变量名
+	Key   string // 配置的key
+	Type  string // 成员的类型
+	Usage string // usage标签
+}
+
+// bindEnv 根据结构体成员绑定环境变量, 变量名通过env标签指定或者由key推导, env:"-"的成员会被忽略
+func (mgr *manager) bindEnv(prefix string, v interface{}) ([]EnvBinding, error) {
+	mgr.mutex.RLock()
+	s := mgr.findSource(client.Env)
+	mgr.mutex.RUnlock()
+	if s == nil {
+		return nil, pkgerrs.New("env source not found")
+	}
+	binder, ok := s.Client().(client.EnvBinder)
+	if !ok {
+		return nil, pkgerrs.New("env source does not support binding")
+	}
+	var bindings []EnvBinding
+	err := walkStruct(prefix, v, func(key string, sf reflect.StructField, fv reflect.Value) error {
+		name := sf.Tag.Get("env")
+		if name == "-" {
+			return nil
+		}
+		if name == "" {
+			name = binder.EnvName(key)
+		}
+		bindings = append(bindings, EnvBinding{
+			Name:  name,
+			Key:   key,
+			Type:  fv.Type().String(),
+			Usage: sf.Tag.Get("usage"),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(bindings))
+	for _, b := range bindings {
+		names[b.Key] = b.Name
+	}
+	binder.BindEnv(names)
+	if r, ok := s.(reloader); ok {
+		if err = r.Reload(envDefaultPath); err != nil {
+			return nil, err
+		}
+	}
+	return bindings, nil
+}
+
+func (mgr *manager) BindEnv(prefix string, v interface{}) error {
+	bindings, err := mgr.bindEnv(prefix, v)
+	if err != nil {
+		return err
+	}
+	mgr.mutex.Lock()
+	mgr.envBindings = append(mgr.envBindings, bindings...)
+	mgr.mutex.Unlock()
+	return nil
+}
+
+func (mgr *manager) EnvBindings() []EnvBinding {
+	mgr.mutex.RLock()
+	bindings := append([]EnvBinding(nil), mgr.envBindings...)
+	mgr.mutex.RUnlock()
+	sort.Slice(bindings, func(i, j int) bool { return bindings[i].Name < bindings[j].Name })
+	return bindings
+}
+
+// WriteEnvTable 以表格的形式输出环境变量的绑定, 用于生成部署文档
+func WriteEnvTable(w io.Writer, bindings []EnvBinding) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "VARIABLE\tKEY\tTYPE\tUSAGE")
+	for _, b := range bindings {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", b.Name, b.Key, b.Type, b.Usage)
+	}
+	return tw.Flush()
+}
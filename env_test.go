@@ -0,0 +1,73 @@
+package vade
+
+import (
+    "bytes"
+    "os"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestWithEnvMapping(t *testing.T) {
+    _ = os.Setenv("VADETEST_DB__POOL__MAX_SIZE", "10")
+    _ = os.Setenv("VADETEST_SECRET_TOKEN", "xxx")
+    _ = os.Setenv("OTHER_VADETEST_KEY", "yyy")
+    defer func() {
+        _ = os.Unsetenv("VADETEST_DB__POOL__MAX_SIZE")
+        _ = os.Unsetenv("VADETEST_SECRET_TOKEN")
+        _ = os.Unsetenv("OTHER_VADETEST_KEY")
+    }()
+    mgr, err := NewManager(WithEnvMapping(
+        WithEnvPrefix("VADETEST_"),
+        WithEnvSeparator("__"),
+        WithEnvCamelCase(),
+        WithEnvMappedKeys()))
+    assert.NoError(t, err)
+
+    v, _ := mgr.Get("db.pool.maxSize")
+    assert.Equal(t, "10", v)
+    v, _ = mgr.Get("secretToken")
+    assert.Equal(t, "xxx", v)
+    _, ok := mgr.Get("VADETEST_SECRET_TOKEN")
+    assert.False(t, ok)
+    _, ok = mgr.Get("HOME")
+    assert.False(t, ok)
+}
+
+func TestBindEnv(t *testing.T) {
+    _ = os.Setenv("DB_POOL_MAX_SIZE", "20")
+    _ = os.Setenv("VADETEST_DSN", "mysql://localhost")
+    defer func() {
+        _ = os.Unsetenv("DB_POOL_MAX_SIZE")
+        _ = os.Unsetenv("VADETEST_DSN")
+    }()
+    mgr, err := NewManager(WithEnvMapping(WithEnvRawKeys()))
+    assert.NoError(t, err)
+
+    var cfg struct {
+        DB struct {
+            DSN  string `yaml:"dsn" env:"VADETEST_DSN" usage:"database dsn"`
+            Pool struct {
+                MaxSize int `yaml:"maxSize"`
+            } `yaml:"pool"`
+            Password string `yaml:"password" env:"-"`
+        } `yaml:"db"`
+    }
+    assert.NoError(t, mgr.BindEnv("", &cfg))
+    assert.NoError(t, mgr.Unmarshal(&cfg))
+    assert.Equal(t, "mysql://localhost", cfg.DB.DSN)
+    assert.Equal(t, 20, cfg.DB.Pool.MaxSize)
+
+    bindings := mgr.EnvBindings()
+    assert.Equal(t, []EnvBinding{
+        {Name: "DB_POOL_MAX_SIZE", Key: "db.pool.maxSize", Type: "int"},
+        {Name: "VADETEST_DSN", Key: "db.dsn", Type: "string", Usage: "database dsn"},
+    }, bindings)
+
+    var buf bytes.Buffer
+    assert.NoError(t, WriteEnvTable(&buf, bindings))
+    assert.Contains(t, buf.String(), "VADETEST_DSN")
+
+    m2, _ := NewManager()
+    assert.Error(t, m2.BindEnv("", &cfg))
+}
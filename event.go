@@ -1,8 +1,11 @@
 package vade
 
 import (
+	"fmt"
 	"math/rand"
 	"regexp"
+	"regexp/syntax"
+	"sort"
 	"sync"
 	"time"
 
@@ -35,6 +38,38 @@ type wrapper struct {
 	handler EventHandler
 }
 
+// normalizePattern 规范化正则表达式中的字面量部分, 使其可以匹配规范化后的key
+func normalizePattern(pattern string, normalize source.KeyNormalizer) string {
+	if normalize == nil {
+		return pattern
+	}
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return pattern
+	}
+	normalizeLiterals(re, normalize)
+	return re.String()
+}
+
+func normalizeLiterals(re *syntax.Regexp, normalize source.KeyNormalizer) {
+	if re.Op == syntax.OpLiteral {
+		re.Rune = []rune(normalize(string(re.Rune)))
+		if len(re.Rune) == 0 {
+			re.Op = syntax.OpEmptyMatch
+		}
+	}
+	for _, sub := range re.Sub {
+		normalizeLiterals(sub, normalize)
+	}
+}
+
+// Watcher 监听者的信息
+type Watcher struct {
+	ID      int64  `json:"id"`
+	Pattern string `json:"pattern"`
+	Handler string `json:"handler"` // 处理者的类型
+}
+
 // dispatcher event dispatcher
 type dispatcher struct {
 	mutex    sync.RWMutex
@@ -78,6 +113,16 @@ func (d *dispatcher) Watch(pattern string, handler EventHandler) (nextId int64)
 	return nextId
 }
 
+func (d *dispatcher) watchers() (watchers []Watcher) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	for _, w := range d.handlers {
+		watchers = append(watchers, Watcher{ID: w.id, Pattern: w.pattern, Handler: fmt.Sprintf("%T", w.handler)})
+	}
+	sort.Slice(watchers, func(i, j int) bool { return watchers[i].ID < watchers[j].ID })
+	return watchers
+}
+
 func (d *dispatcher) handlersOf(key string) (hdrs []EventHandler) {
 	d.mutex.RLock()
 	defer d.mutex.RUnlock()
@@ -2,33 +2,144 @@ package vade
 
 import (
 	"flag"
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"time"
 )
 
 // Global flags
 var (
-	_flagSet   = flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ExitOnError)
-	_bindFlags = map[string]interface{}{}
+	_flagSet = flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ExitOnError)
 )
 
-// Flag 定义命令行参数
+// Flag 定义命令行参数, 不支持的类型会panic
 func Flag(key string, def interface{}, usage string) {
-	switch def.(type) {
+	if err := defineFlag(_flagSet, key, def, usage); err != nil {
+		panic(err)
+	}
+}
+
+// StructFlags 根据结构体定义命令行参数, 参数名为prefix加上成员的key,
+// 成员的值作为默认值, usage标签作为说明, flag:"-"的成员会被忽略。
+func StructFlags(prefix string, v interface{}) error {
+	return defineStructFlags(_flagSet, prefix, v)
+}
+
+// DefineFlag 在指定的FlagSet中定义命令行参数
+func DefineFlag(fs *flag.FlagSet, key string, def interface{}, usage string) error {
+	return defineFlag(fs, key, def, usage)
+}
+
+// DefineStructFlags 根据结构体在指定的FlagSet中定义命令行参数
+func DefineStructFlags(fs *flag.FlagSet, prefix string, v interface{}) error {
+	return defineStructFlags(fs, prefix, v)
+}
+
+func defineFlag(fs *flag.FlagSet, key string, def interface{}, usage string) error {
+	switch x := def.(type) {
 	case int:
-		_bindFlags[key] = _flagSet.Int(key, def.(int), usage)
+		fs.Int(key, x, usage)
+	case int64:
+		fs.Int64(key, x, usage)
 	case uint:
-		_bindFlags[key] = _flagSet.Uint(key, def.(uint), usage)
+		fs.Uint(key, x, usage)
+	case uint64:
+		fs.Uint64(key, x, usage)
 	case bool:
-		_bindFlags[key] = _flagSet.Bool(key, def.(bool), usage)
+		fs.Bool(key, x, usage)
 	case string:
-		_bindFlags[key] = _flagSet.String(key, def.(string), usage)
+		fs.String(key, x, usage)
 	case time.Duration:
-		_bindFlags[key] = _flagSet.Duration(key, def.(time.Duration), usage)
+		fs.Duration(key, x, usage)
 	case float64:
-		_bindFlags[key] = _flagSet.Float64(key, def.(float64), usage)
+		fs.Float64(key, x, usage)
 	case float32:
-		_bindFlags[key] = _flagSet.Float64(key, float64(def.(float32)), usage)
+		fs.Float64(key, float64(x), usage)
+	case []string:
+		values := append([]string(nil), x...)
+		fs.Var(&stringSliceValue{values: &values}, key, usage)
+	case flag.Value:
+		fs.Var(x, key, usage)
+	default:
+		return fmt.Errorf("vade: unsupported flag type %T of %q", def, key)
+	}
+	return nil
+}
+
+func defineStructFlags(fs *flag.FlagSet, prefix string, v interface{}) error {
+	return walkStruct(prefix, v, func(key string, sf reflect.StructField, fv reflect.Value) error {
+		if sf.Tag.Get("flag") == "-" {
+			return nil
+		}
+		return defineField(fs, key, fv, sf.Tag.Get("usage"))
+	})
+}
+
+// defineField 定义成员对应的参数, map等不能作为参数的成员会被忽略
+func defineField(fs *flag.FlagSet, key string, fv reflect.Value, usage string) error {
+	var def interface{}
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		def = int(fv.Int())
+	case reflect.Int64:
+		if fv.Type() == durationType {
+			def = time.Duration(fv.Int())
+		} else {
+			def = fv.Int()
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		def = uint(fv.Uint())
+	case reflect.Uint64:
+		def = fv.Uint()
+	case reflect.Float32, reflect.Float64:
+		def = fv.Float()
+	case reflect.String:
+		def = fv.String()
+	case reflect.Bool:
+		def = fv.Bool()
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() != reflect.String {
+			return nil
+		}
+		values := make([]string, fv.Len())
+		for i := range values {
+			values[i] = fv.Index(i).String()
+		}
+		def = values
+	default:
+		return nil
+	}
+	return defineFlag(fs, key, def, usage)
+}
+
+// stringSliceValue 字符串列表参数, 可以重复设置或者使用逗号分割
+type stringSliceValue struct {
+	values  *[]string
+	changed bool
+}
+
+func (s *stringSliceValue) Set(v string) error {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		items = append(items, strings.TrimSpace(item))
+	}
+	if !s.changed {
+		*s.values = items
+		s.changed = true
+	} else {
+		*s.values = append(*s.values, items...)
+	}
+	return nil
+}
+
+func (s *stringSliceValue) String() string {
+	if s.values == nil {
+		return ""
 	}
+	return strings.Join(*s.values, ",")
 }
+
+func (s *stringSliceValue) Get() interface{} { return *s.values }
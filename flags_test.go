@@ -0,0 +1,67 @@
+package vade
+
+import (
+    "flag"
+    "io/ioutil"
+    "testing"
+    "time"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestDefineStructFlags(t *testing.T) {
+    type Config struct {
+        Server struct {
+            Port    int           `yaml:"port" usage:"server port"`
+            Timeout time.Duration `yaml:"timeout"`
+        } `yaml:"server"`
+        Hosts   []string          `yaml:"hosts"`
+        Workers uint64            `yaml:"workers"`
+        Labels  map[string]string `yaml:"labels"`
+        Secret  string            `yaml:"secret" flag:"-"`
+    }
+    cfg := &Config{}
+    cfg.Server.Port = 80
+    fs := flag.NewFlagSet("test", flag.ContinueOnError)
+    assert.NoError(t, defineStructFlags(fs, "app", cfg))
+
+    f := fs.Lookup("app.server.port")
+    if assert.NotNil(t, f) {
+        assert.Equal(t, "80", f.DefValue)
+        assert.Equal(t, "server port", f.Usage)
+    }
+    assert.NotNil(t, fs.Lookup("app.server.timeout"))
+    assert.NotNil(t, fs.Lookup("app.workers"))
+    assert.Nil(t, fs.Lookup("app.labels"))
+    assert.Nil(t, fs.Lookup("app.secret"))
+
+    assert.NoError(t, fs.Parse([]string{"-app.hosts=a,b", "-app.hosts=c", "-app.workers=3"}))
+    assert.Equal(t, []string{"a", "b", "c"}, fs.Lookup("app.hosts").Value.(flag.Getter).Get())
+    assert.Equal(t, uint64(3), fs.Lookup("app.workers").Value.(flag.Getter).Get())
+}
+
+func TestDefineFlagUnsupported(t *testing.T) {
+    fs := flag.NewFlagSet("test", flag.ContinueOnError)
+    assert.Error(t, defineFlag(fs, "m", map[string]string{}, ""))
+}
+
+func TestWithFlagSet(t *testing.T) {
+    newFlags := func() *flag.FlagSet {
+        fs := flag.NewFlagSet("test", flag.ContinueOnError)
+        fs.SetOutput(ioutil.Discard)
+        assert.NoError(t, DefineFlag(fs, "server.port", 80, "server port"))
+        return fs
+    }
+    m1, err := NewManager(WithFlagSet(newFlags(), []string{"-server.port=8080"}))
+    assert.NoError(t, err)
+    m2, err := NewManager(WithFlagSet(newFlags(), []string{}))
+    assert.NoError(t, err)
+
+    v, _ := m1.Get("server.port")
+    assert.Equal(t, 8080, v)
+    _, ok := m2.Get("server.port")
+    assert.False(t, ok)
+
+    _, err = NewManager(WithFlagSet(newFlags(), []string{"-unknown"}))
+    assert.Error(t, err)
+}
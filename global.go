@@ -1,6 +1,10 @@
 package vade
 
-import "github.com/derry6/vade-go/source"
+import (
+	"time"
+
+	"github.com/derry6/vade-go/source"
+)
 
 var (
 	_mgr Manager
@@ -49,6 +53,51 @@ func Get(key string) (value interface{}, ok bool) {
 	return _mgr.Get(key)
 }
 
+// RegisterAlias 注册别名, 读取newKey时可以读取到oldKey设置的值
+func RegisterAlias(oldKey string, newKey string) {
+	_mgr.RegisterAlias(oldKey, newKey)
+}
+
+// Deprecate 声明废弃的key, replacement不为空时注册为别名
+func Deprecate(key string, message string, replacement string) {
+	_mgr.Deprecate(key, message, replacement)
+}
+
+// DeprecatedKeys 返回正在使用的废弃key
+func DeprecatedKeys() []DeprecatedKey {
+	return _mgr.DeprecatedKeys()
+}
+
+// History 返回配置变更记录
+func History() []*ChangeSet {
+	return _mgr.History()
+}
+
+// Diff 比较两个版本的配置
+func Diff(from int64, to int64) ([]*Change, error) {
+	return _mgr.Diff(from, to)
+}
+
+// Rollback 将生效的配置固定为历史版本的配置
+func Rollback(version int64) error {
+	return _mgr.Rollback(version)
+}
+
+// ClearRollback 取消回滚
+func ClearRollback() {
+	_mgr.ClearRollback()
+}
+
+// Degraded 返回从快照加载的source:path
+func Degraded() []string {
+	return _mgr.Degraded()
+}
+
+// Origin 返回key生效值的来源
+func Origin(key string) (sourceName string, path string, ok bool) {
+	return _mgr.Origin(key)
+}
+
 // Set 设置kv， 覆盖配置。
 func Set(key string, value interface{}) {
 	_mgr.Set(key, value)
@@ -64,6 +113,11 @@ func Delete(key string) {
 	_mgr.Delete(key)
 }
 
+// DeleteOverride 只删除覆盖的key, 保留默认值
+func DeleteOverride(key string) {
+	_mgr.DeleteOverride(key)
+}
+
 // Watch 监听某个满足pattern模式的key变化的事件。
 func Watch(pattern string, cb EventHandler) (id int64) {
 	return _mgr.Watch(pattern, cb)
@@ -73,3 +127,53 @@ func Watch(pattern string, cb EventHandler) (id int64) {
 func Unwatch(id int64) {
 	_mgr.Unwatch(id)
 }
+
+// Close 停止后台任务并关闭所有的source
+func Close() error {
+	return _mgr.Close()
+}
+
+// Watchers 返回当前的监听者
+func Watchers() []Watcher {
+	return _mgr.Watchers()
+}
+
+// NewIntProperty 创建int类型的动态属性
+func NewIntProperty(key string, def int) *IntProperty {
+	return _mgr.IntProperty(key, def)
+}
+
+// NewInt64Property 创建int64类型的动态属性
+func NewInt64Property(key string, def int64) *Int64Property {
+	return _mgr.Int64Property(key, def)
+}
+
+// NewFloatProperty 创建float64类型的动态属性
+func NewFloatProperty(key string, def float64) *FloatProperty {
+	return _mgr.FloatProperty(key, def)
+}
+
+// NewStringProperty 创建string类型的动态属性
+func NewStringProperty(key string, def string) *StringProperty {
+	return _mgr.StringProperty(key, def)
+}
+
+// NewBoolProperty 创建bool类型的动态属性
+func NewBoolProperty(key string, def bool) *BoolProperty {
+	return _mgr.BoolProperty(key, def)
+}
+
+// NewDurationProperty 创建time.Duration类型的动态属性
+func NewDurationProperty(key string, def time.Duration) *DurationProperty {
+	return _mgr.DurationProperty(key, def)
+}
+
+// BindEnv 根据结构体成员绑定环境变量, 变量名通过env标签指定或者由key推导
+func BindEnv(prefix string, v interface{}) error {
+	return _mgr.BindEnv(prefix, v)
+}
+
+// EnvBindings 返回绑定的所有环境变量
+func EnvBindings() []EnvBinding {
+	return _mgr.EnvBindings()
+}
@@ -0,0 +1,350 @@
+package vade
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	pkgerrs "github.com/pkg/errors"
+
+	"github.com/derry6/vade-go/pkg/log"
+	"github.com/derry6/vade-go/source"
+)
+
+// DefaultHistorySize 建议保留的变更记录数量, 如WithHistory(DefaultHistorySize)
+const DefaultHistorySize = 100
+
+const originRollback = "rollback"
+
+// Change 单个key生效值的变化
+type Change struct {
+	Key    string        `json:"key"`
+	Action source.Action `json:"action,omitempty"`
+	From   interface{}   `json:"from,omitempty"`
+	To     interface{}   `json:"to,omitempty"`
+	Source string        `json:"source,omitempty"` // 产生变更的source
+	Path   string        `json:"path,omitempty"`
+	// 变更被更高优先级的source覆盖时, 生效的source, 此时生效值没有变化
+	ShadowedBy string `json:"shadowedBy,omitempty"`
+}
+
+// ChangeSet 一次应用的配置变更
+type ChangeSet struct {
+	Version int64     `json:"version"`
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source,omitempty"`
+	Changes []*Change `json:"changes"`
+}
+
+// pin 回滚固定的生效值, deleted为true时key被隐藏
+type pin struct {
+	value   interface{}
+	deleted bool
+}
+
+// history 有界的变更记录, 被淘汰的变更合并到base中
+type history struct {
+	size        int
+	base        map[string]interface{} // baseVersion时的配置
+	baseVersion int64
+	sets        []*ChangeSet
+	version     int64
+	writer      io.Writer
+	mutex       sync.RWMutex
+}
+
+func newHistory(size int, file string) (*history, error) {
+	if size <= 0 {
+		return nil, nil
+	}
+	h := &history{size: size, base: map[string]interface{}{}}
+	if file != "" {
+		f, err := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, pkgerrs.Wrap(err, "open history file")
+		}
+		h.writer = f
+	}
+	return h, nil
+}
+
+// close 关闭变更记录文件
+func (h *history) close() error {
+	if h == nil {
+		return nil
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	c, ok := h.writer.(io.Closer)
+	h.writer = nil
+	if !ok {
+		return nil
+	}
+	return c.Close()
+}
+
+func applyChanges(values map[string]interface{}, changes []*Change) {
+	for _, c := range changes {
+		switch {
+		case c.ShadowedBy != "":
+		case c.Action == Deleted:
+			delete(values, c.Key)
+		default:
+			values[c.Key] = c.To
+		}
+	}
+}
+
+func (h *history) record(origin string, changes []*Change) {
+	if h == nil || len(changes) == 0 {
+		return
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.version++
+	cs := &ChangeSet{Version: h.version, Time: time.Now(), Source: origin, Changes: changes}
+	h.sets = append(h.sets, cs)
+	for len(h.sets) > h.size {
+		applyChanges(h.base, h.sets[0].Changes)
+		h.baseVersion = h.sets[0].Version
+		h.sets = h.sets[1:]
+	}
+	if h.writer != nil {
+		data, err := json.Marshal(cs)
+		if err == nil {
+			_, err = h.writer.Write(append(data, '\n'))
+		}
+		if err != nil {
+			log.Get().Errorf("Can't write history of version %d: %v", cs.Version, err)
+		}
+	}
+}
+
+func (h *history) list() []*ChangeSet {
+	if h == nil {
+		return nil
+	}
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	sets := make([]*ChangeSet, len(h.sets))
+	copy(sets, h.sets)
+	return sets
+}
+
+// valuesAt 返回version时的配置
+func (h *history) valuesAt(version int64) (map[string]interface{}, error) {
+	if h == nil {
+		return nil, pkgerrs.New("history disabled")
+	}
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	if version < h.baseVersion || version > h.version {
+		return nil, pkgerrs.Errorf("version %d not in history [%d, %d]", version, h.baseVersion, h.version)
+	}
+	values := make(map[string]interface{}, len(h.base))
+	for k, v := range h.base {
+		values[k] = v
+	}
+	for _, cs := range h.sets {
+		if cs.Version > version {
+			break
+		}
+		applyChanges(values, cs.Changes)
+	}
+	return values, nil
+}
+
+// DiffValues 比较两份扁平的配置, 按key排序
+func DiffValues(from, to map[string]interface{}) (changes []*Change) {
+	for k, v := range from {
+		if v2, ok := to[k]; !ok {
+			changes = append(changes, &Change{Key: k, Action: Deleted, From: v})
+		} else if !reflect.DeepEqual(v, v2) {
+			changes = append(changes, &Change{Key: k, Action: Updated, From: v, To: v2})
+		}
+	}
+	for k, v := range to {
+		if _, ok := from[k]; !ok {
+			changes = append(changes, &Change{Key: k, Action: Created, To: v})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return
+}
+
+// effectiveValues 返回keys当前生效的值(不做变量替换), 不存在的key被忽略
+func (mgr *manager) effectiveValues(keys []string) map[string]interface{} {
+	mgr.mutex.RLock()
+	defer mgr.mutex.RUnlock()
+	return mgr.unsafeEffectiveValues(keys)
+}
+
+func (mgr *manager) unsafeEffectiveValues(keys []string) map[string]interface{} {
+	values := make(map[string]interface{}, len(keys))
+	for _, k := range keys {
+		if v, ok := mgr.unsafeLookup(k); ok {
+			values[k] = v
+		}
+	}
+	return values
+}
+
+// recordChanges 根据变化前后的生效值记录变更
+func (mgr *manager) recordChanges(origin string, events []*Event, before, after map[string]interface{}) {
+	if mgr.history == nil && mgr.snapshotDir == "" {
+		return
+	}
+	var changes []*Change
+	for _, ev := range events {
+		from, had := before[ev.Key]
+		to, has := after[ev.Key]
+		c := &Change{Key: ev.Key, From: from, To: to, Source: ev.Source, Path: ev.Path}
+		if c.Source == "" {
+			c.Source = origin
+		}
+		switch {
+		case !had && has:
+			c.Action = Created
+		case had && !has:
+			c.Action = Deleted
+		case had && has && !reflect.DeepEqual(from, to):
+			c.Action = Updated
+		case has && ev.Action != Deleted:
+			// 生效值没有变化, 变更被覆盖
+			c.Action = ev.Action
+			c.ShadowedBy, _, _ = mgr.Origin(ev.Key)
+			if c.ShadowedBy == c.Source {
+				continue
+			}
+		default:
+			continue
+		}
+		changes = append(changes, c)
+	}
+	if len(changes) > 0 {
+		mgr.history.record(origin, changes)
+		mgr.saveSnapshot()
+	}
+}
+
+func valuesOf(key string, value interface{}, exists bool) map[string]interface{} {
+	if !exists {
+		return nil
+	}
+	return map[string]interface{}{key: value}
+}
+
+// recordSourceAdded 记录添加source引起的变更
+func (mgr *manager) recordSourceAdded(src source.Source, keys []string, before map[string]interface{}) {
+	if mgr.history == nil && mgr.snapshotDir == "" {
+		return
+	}
+	events := make([]*Event, 0, len(keys))
+	for _, k := range keys {
+		ev := source.NewEvent(Created, k)
+		ev.Source = src.Name()
+		if l, ok := src.(pathLocator); ok {
+			ev.Path, _ = l.PathOf(k)
+		}
+		events = append(events, ev)
+	}
+	mgr.recordChanges(src.Name(), events, before, mgr.effectiveValues(keys))
+}
+
+func (mgr *manager) History() []*ChangeSet {
+	return mgr.history.list()
+}
+
+func (mgr *manager) Diff(from int64, to int64) ([]*Change, error) {
+	v1, err := mgr.history.valuesAt(from)
+	if err != nil {
+		return nil, err
+	}
+	v2, err := mgr.history.valuesAt(to)
+	if err != nil {
+		return nil, err
+	}
+	return DiffValues(v1, v2), nil
+}
+
+// Rollback 将生效的配置固定为version时的配置, version之后新增的key被隐藏。
+// 固定的值优先于所有的source和覆盖配置, 直到ClearRollback或者通过Set, Delete修改该key。
+func (mgr *manager) Rollback(version int64) error {
+	values, err := mgr.history.valuesAt(version)
+	if err != nil {
+		return err
+	}
+	mgr.changePins(func() []string {
+		var keys []string
+		for k, v := range values {
+			if cur, ok := mgr.unsafeLookup(k); !ok || !reflect.DeepEqual(cur, v) {
+				mgr.pins[k] = pin{value: v}
+				keys = append(keys, k)
+			}
+		}
+		for _, k := range mgr.unsafeKeys() {
+			if _, ok := values[k]; ok {
+				continue
+			}
+			if _, ok := mgr.unsafeLookup(k); ok {
+				mgr.pins[k] = pin{deleted: true}
+				keys = append(keys, k)
+			}
+		}
+		return keys
+	})
+	return nil
+}
+
+// ClearRollback 取消回滚, 恢复source, 覆盖配置和默认值的生效值
+func (mgr *manager) ClearRollback() {
+	mgr.changePins(func() []string {
+		keys := make([]string, 0, len(mgr.pins))
+		for k := range mgr.pins {
+			keys = append(keys, k)
+		}
+		mgr.pins = map[string]pin{}
+		return keys
+	})
+}
+
+// changePins 修改回滚固定的值, modify返回可能变化的key, 记录并派发生效值的变化
+func (mgr *manager) changePins(modify func() (keys []string)) {
+	mgr.mutex.Lock()
+	all := mgr.unsafeKeys()
+	for k := range mgr.pins {
+		all = append(all, k)
+	}
+	before := mgr.unsafeEffectiveValues(all)
+	keys := modify()
+	after := mgr.unsafeEffectiveValues(keys)
+	mgr.mutex.Unlock()
+	sort.Strings(keys)
+	var events []*Event
+	for _, k := range keys {
+		from, existed := before[k]
+		to, exists := after[k]
+		var ev *Event
+		switch {
+		case !existed && exists:
+			ev = source.NewEvent(Created, k)
+		case existed && !exists:
+			ev = source.NewEvent(Deleted, k)
+		case existed && exists && !reflect.DeepEqual(from, to):
+			ev = source.NewEvent(Updated, k)
+		default:
+			continue
+		}
+		ev.Source, ev.ValueFrom, ev.ValueTo = originRollback, from, to
+		events = append(events, ev)
+	}
+	if len(events) == 0 {
+		return
+	}
+	mgr.recordChanges(originRollback, events, before, after)
+	mgr.dispatcher.Dispatch(mgr.withAliasEvents(events, before))
+}
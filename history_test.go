@@ -0,0 +1,85 @@
+package vade
+
+import (
+    "bytes"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestHistory(t *testing.T) {
+    dir, err := ioutil.TempDir("", "vade")
+    assert.NoError(t, err)
+    defer os.RemoveAll(dir)
+    file := filepath.Join(dir, "app.yaml")
+    assert.NoError(t, ioutil.WriteFile(file, []byte("a: 1\nb: x\n"), 0644))
+    historyFile := filepath.Join(dir, "history.jsonl")
+
+    mgr, err := NewManager(WithFileSource([]string{file}, nil), WithHistory(4), WithHistoryFile(historyFile))
+    assert.NoError(t, err)
+    mgr.Set("a", 2)
+    mgr.SetDefault("a", 0)
+    mgr.Delete("b")
+
+    sets := mgr.History()
+    if assert.Len(t, sets, 3) {
+        assert.Equal(t, int64(1), sets[0].Version)
+        assert.Equal(t, "file", sets[0].Source)
+        assert.Equal(t, "overrides", sets[1].Source)
+        if assert.Len(t, sets[2].Changes, 1) {
+            assert.Equal(t, "overrides", sets[2].Changes[0].ShadowedBy)
+        }
+    }
+    changes, err := mgr.Diff(1, 2)
+    assert.NoError(t, err)
+    if assert.Len(t, changes, 1) {
+        assert.Equal(t, &Change{Key: "a", Action: Updated, From: 1, To: 2}, changes[0])
+    }
+
+    // version之后新增的key被隐藏
+    mgr.Set("c", 1)
+    assert.NoError(t, mgr.Rollback(1))
+    v, _ := mgr.Get("a")
+    assert.Equal(t, 1, v)
+    _, ok := mgr.Get("c")
+    assert.False(t, ok)
+    assert.NotContains(t, mgr.Keys(), "c")
+    src, _, _ := mgr.Origin("a")
+    assert.Equal(t, "rollback", src)
+    // 超出保留范围的版本
+    _, err = mgr.Diff(0, 1)
+    assert.Error(t, err)
+    assert.Len(t, mgr.History(), 4)
+
+    // 修改key时不再固定回滚的值
+    mgr.Set("a", 3)
+    v, _ = mgr.Get("a")
+    assert.Equal(t, 3, v)
+    // 取消回滚
+    mgr.ClearRollback()
+    v, _ = mgr.Get("c")
+    assert.Equal(t, 1, v)
+
+    // 关闭后不再写入文件
+    data, err := ioutil.ReadFile(historyFile)
+    assert.NoError(t, err)
+    lines := bytes.Count(data, []byte("\n"))
+    assert.Equal(t, 7, lines)
+    if info, err := os.Stat(historyFile); assert.NoError(t, err) {
+        assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+    }
+    assert.NoError(t, mgr.Close())
+    mgr.Set("a", 4)
+    data, err = ioutil.ReadFile(historyFile)
+    assert.NoError(t, err)
+    assert.Equal(t, lines, bytes.Count(data, []byte("\n")))
+
+    // 默认不记录
+    mgr, err = NewManager()
+    assert.NoError(t, err)
+    mgr.Set("a", 1)
+    assert.Empty(t, mgr.History())
+}
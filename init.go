@@ -5,50 +5,128 @@ import (
     "github.com/derry6/vade-go/pkg/log"
     "github.com/derry6/vade-go/source"
     "github.com/derry6/vade-go/source/client"
+    "github.com/derry6/vade-go/source/parser"
 )
 
-func (mgr *manager) initFlagSource(opts ...source.Option) error {
+func (mgr *manager) initFlagSource(fc *flagConfig, opts ...source.Option) error {
     cfg := client.DefaultConfig()
-    cfg.Flags = _bindFlags
     cfg.FlagSet = _flagSet
+    if fc.flagSet != nil {
+        cfg.FlagSet = fc.flagSet
+    }
+    cfg.PFlagSet = fc.pflagSet
+    cfg.Args = fc.args
     c, err := client.New(client.Flag, cfg)
     if err != nil {
         return err
     }
     s := source.New(client.Flag, c, opts...)
-    _ = s.AddPath("default", source.WithPathRequired())
+    if err = s.AddPath("default", source.WithPathRequired()); err != nil {
+        return err
+    }
     return mgr.AddSource(s)
 }
 
-func (mgr *manager) initEnvSource(opts ...source.Option) error {
+func (mgr *manager) initEnvSource(vOpts *options) error {
     cfg := client.DefaultConfig()
+    cfg.EnvMapper = vOpts.envMapper
     c, err := client.New(client.Env, cfg)
     if err != nil {
         return err
     }
-    s := source.New(client.Env, c, opts...)
-    _ = s.AddPath("default", source.WithPathRequired())
+    s := source.New(client.Env, c, vOpts.envOpts...)
+    _ = s.AddPath(envDefaultPath, source.WithPathRequired())
+    // .env 文件的优先级低于环境变量
+    pOpts := []source.PathOption{source.WithPathPriority(-1)}
+    if vOpts.envMapper != nil {
+        pOpts = append(pOpts, source.WithPathParser(parser.NewDotenvMapper(vOpts.envMapper)))
+    }
+    for _, f := range vOpts.dotenvs {
+        if err = s.AddPath(f, pOpts...); err != nil {
+            return err
+        }
+    }
     return mgr.AddSource(s)
 }
 
-func (mgr *manager) initFileSource(requires, optionals []string, opts ...source.Option) error {
+// addFileDir 添加目录中的文件, 并监听文件的添加和删除
+func (mgr *manager) addFileDir(s source.Source, c client.Client, d dirConfig) error {
+    var pOpts []source.PathOption
+    if d.required {
+        pOpts = append(pOpts, source.WithPathRequired())
+    }
+    if dw, ok := c.(client.DirWatcher); ok {
+        err := dw.WatchDir(d.dir, &d.opts, func(added, removed []string) {
+            if r, ok := s.(source.PathRemover); ok {
+                for _, f := range removed {
+                    _ = r.RemovePath(f)
+                }
+            }
+            for _, f := range added {
+                if err := s.AddPath(f, pOpts...); err != nil {
+                    log.Get().Warnf("Can't add config file %q: %v", f, err)
+                }
+            }
+        })
+        if err != nil {
+            log.Get().Warnf("Can't watch directory %q: %v", d.dir, err)
+        }
+    }
+    files, err := client.ListDir(d.dir, &d.opts)
+    if err != nil {
+        if d.required {
+            return mgr.addMissing(s, d.dir, err, pOpts...)
+        }
+        log.Get().Warnf("Can't list directory %q: %v", d.dir, err)
+    }
+    for _, f := range files {
+        if err = mgr.addPath(s, f, pOpts...); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// addFiles 添加root中的配置文件, 必须的文件不可用时可以从快照启动
+func (mgr *manager) addFiles(s source.Source, root string, required bool) error {
+    var pOpts []source.PathOption
+    if required {
+        pOpts = append(pOpts, source.WithPathRequired())
+    }
+    files, err := client.ListDir(root, nil)
+    if err != nil {
+        if required {
+            return mgr.addMissing(s, root, err, pOpts...)
+        }
+        log.Get().Warnf("Can't list config files in %q: %v", root, err)
+    }
+    for _, f := range files {
+        if err = mgr.addPath(s, f, pOpts...); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (mgr *manager) initFileSource(vOpts *options) error {
     cfg := client.DefaultConfig()
     c, err := client.New(client.File, cfg)
     if err != nil {
         return err
     }
-    s := source.New(client.File, c, opts...)
-    for _, require := range requires {
-        if err = filesInDir(require, func(f string) error {
-            return s.AddPath(f, source.WithPathRequired())
-        }); err != nil {
+    s := source.New(client.File, c, vOpts.fileOpts...)
+    for _, require := range vOpts.requireds {
+        if err = mgr.addFiles(s, require, true); err != nil {
+            return err
+        }
+    }
+    for _, optional := range vOpts.optionals {
+        if err = mgr.addFiles(s, optional, false); err != nil {
             return err
         }
     }
-    for _, optional := range optionals {
-        if err = filesInDir(optional, func(f string) error {
-            return s.AddPath(f)
-        }); err != nil {
+    for _, d := range vOpts.dirs {
+        if err = mgr.addFileDir(s, c, d); err != nil {
             return err
         }
     }
@@ -74,19 +152,20 @@ func (mgr *manager) init(vOpts *options) (err error) {
         SetLogger(vOpts.logger)
     }
     mgr.expander = expander.New(mgr.unsafeGet, vOpts.epOpts...)
+    mgr.expandOpts = vOpts.epOpts
     mgr.expandDisabled = vOpts.epDisabled
     if vOpts.withFile {
-        if err = mgr.initFileSource(vOpts.requireds, vOpts.optionals, vOpts.fileOpts...); err != nil {
+        if err = mgr.initFileSource(vOpts); err != nil {
             return err
         }
     }
     if vOpts.withEnv {
-        if err = mgr.initEnvSource(vOpts.envOpts...); err != nil {
+        if err = mgr.initEnvSource(vOpts); err != nil {
             return err
         }
     }
     if vOpts.withFlag {
-        if err = mgr.initFlagSource(vOpts.flagOpts...); err != nil {
+        if err = mgr.initFlagSource(&vOpts.flags, vOpts.flagOpts...); err != nil {
             return err
         }
     }
@@ -98,6 +177,10 @@ func SetLogger(logger log.Logger) {
 }
 
 func Init(opts ...Option) (err error) {
-    _mgr, err = newManager(opts...)
-    return err
+    mgr, err := newManager(opts...)
+    if err != nil {
+        return err
+    }
+    _mgr = mgr
+    return nil
 }
@@ -1,8 +1,10 @@
 package vade
 
 import (
+	"reflect"
 	"sort"
 	"sync"
+	"time"
 
 	pkgerrs "github.com/pkg/errors"
 
@@ -27,10 +29,56 @@ type Manager interface {
 	Set(key string, value interface{})
 	SetDefault(key string, value interface{})
 	Delete(key string)
+	// 只删除Set设置的覆盖配置, 保留SetDefault设置的默认值
+	DeleteOverride(key string)
 
 	// 监听事件
 	Watch(pattern string, handler EventHandler) (watchId int64)
 	Unwatch(id int64)
+	// 返回当前的监听者
+	Watchers() []Watcher
+
+	// 返回key生效值的来源, 本地设置的值来源为overrides或defaults
+	Origin(key string) (sourceName string, path string, ok bool)
+
+	// 注册别名, 读取newKey时可以读取到oldKey设置的值, 对子key同样生效
+	RegisterAlias(oldKey string, newKey string)
+	// 声明废弃的key, replacement不为空时注册为别名, 使用时告警一次
+	Deprecate(key string, message string, replacement string)
+	// 返回正在使用的废弃key
+	DeprecatedKeys() []DeprecatedKey
+
+	// 变更记录, 按版本从旧到新
+	History() []*ChangeSet
+	// 比较两个版本的配置
+	Diff(from int64, to int64) ([]*Change, error)
+	// 将生效的配置固定为历史版本的配置
+	Rollback(version int64) error
+	// 取消回滚
+	ClearRollback()
+
+	// 从快照加载的source:path, 为空时没有降级
+	Degraded() []string
+
+	// 停止后台任务并关闭所有的source
+	Close() error
+
+	// 解析到结构体
+	Unmarshal(out interface{}, opts ...UnmarshalOption) error
+	// 返回prefix下配置的视图, key为相对prefix的key
+	Sub(prefix string) Manager
+
+	// 根据结构体成员绑定环境变量, 变量名通过env标签指定或者由key推导
+	BindEnv(prefix string, v interface{}) error
+	EnvBindings() []EnvBinding
+
+	// 动态属性, key变化时自动更新
+	IntProperty(key string, def int) *IntProperty
+	Int64Property(key string, def int64) *Int64Property
+	FloatProperty(key string, def float64) *FloatProperty
+	StringProperty(key string, def string) *StringProperty
+	BoolProperty(key string, def bool) *BoolProperty
+	DurationProperty(key string, def time.Duration) *DurationProperty
 }
 
 type sourceLess []source.Source
@@ -45,25 +93,99 @@ type manager struct {
 	defaults       map[string]interface{}
 	overrides      map[string]interface{}
 	expander       expander.Expander
+	expandOpts     []expander.Option
 	expandDisabled bool
 	dispatcher     *dispatcher
+	pendingImports []pendingImport
+	imports        map[string]int // 跨source导入的引用计数
+	envBindings    []EnvBinding
+	normalize      source.KeyNormalizer
+	originals      map[string]string // Set和SetDefault规范化之前的key
+	aliases        []keyAlias
+	deprecated     map[string]*DeprecatedKey
+	warned         map[string]bool // 已经告警过的废弃key
+	history        *history
+	pins           map[string]pin // 回滚固定的生效值
+	snapshotDir    string
+	snapshotBoot   bool
+	snapshotMutex  sync.Mutex
+	degraded       map[string]bool // 从快照加载的source:path
+	stop           chan struct{}   // 关闭时停止后台任务
+	closeOnce      sync.Once
 	mutex          sync.RWMutex
 }
 
-func (mgr *manager) AddSource(newSrc source.Source) (err error) {
+// originalKeyer 可以返回规范化之前的key的source
+type originalKeyer interface {
+	OriginalKey(key string) (string, bool)
+}
+
+func (mgr *manager) setOriginal(key, raw string) {
+	if key != raw {
+		mgr.originals[key] = raw
+	}
+}
+
+// originalKey 返回规范化之前的key, 用于解码map的key
+func (mgr *manager) originalKey(key string) string {
+	if mgr.normalize == nil {
+		return key
+	}
+	mgr.mutex.RLock()
+	defer mgr.mutex.RUnlock()
+	if _, ok := mgr.overrides[key]; !ok {
+		if k, ok := mgr.ksMap[key].(originalKeyer); ok {
+			if raw, ok := k.OriginalKey(key); ok {
+				return raw
+			}
+		}
+	}
+	if raw, ok := mgr.originals[key]; ok {
+		return raw
+	}
+	return key
+}
+
+// keyNormalizerSetter 支持设置key规范化函数的source
+type keyNormalizerSetter interface {
+	SetKeyNormalizer(fn source.KeyNormalizer)
+}
+
+func (mgr *manager) normalizeKey(key string) string {
+	if mgr.normalize == nil {
+		return key
+	}
+	return mgr.normalize(key)
+}
+
+// importer 支持跨source导入配置的source
+type importer interface {
+	OnImport(add source.ImportFunc, remove source.UnimportFunc)
+}
+
+// importRemover 可以区分导入的path和直接添加的path的source
+type importRemover interface {
+	RemoveImport(path string) error
+}
+
+func (mgr *manager) addSource(newSrc source.Source) (added bool) {
 	mgr.mutex.Lock()
 	defer mgr.mutex.Unlock()
 
 	for _, p := range mgr.sources {
 		if p == newSrc {
-			return
+			return false
 		}
 		if p.Name() == newSrc.Name() {
-			return
+			return false
 		}
 	}
+	if s, ok := newSrc.(keyNormalizerSetter); ok && mgr.normalize != nil {
+		s.SetKeyNormalizer(mgr.normalize)
+	}
 	keys := newSrc.Keys()
 	for _, k := range keys {
+		k = mgr.normalizeKey(k)
 		px, ok := mgr.ksMap[k]
 		if ok { // 优先级较高
 			if newSrc.Priority() > px.Priority() {
@@ -81,6 +203,41 @@ func (mgr *manager) AddSource(newSrc source.Source) (err error) {
 	sources := append(mgr.sources, newSrc)
 	sort.Sort(sourceLess(sources))
 	mgr.sources = sources
+	return true
+}
+
+func (mgr *manager) AddSource(newSrc source.Source) (err error) {
+	keys := newSrc.Keys()
+	for i, k := range keys {
+		keys[i] = mgr.normalizeKey(k)
+	}
+	before := mgr.effectiveValues(keys)
+	if !mgr.addSource(newSrc) {
+		return nil
+	}
+	mgr.recordSourceAdded(newSrc, keys, before)
+	mgr.warnDeprecated(keys)
+	if im, ok := newSrc.(importer); ok {
+		im.OnImport(mgr.importPath, mgr.unimportPath)
+	}
+	// 处理之前导入到该source的path
+	mgr.mutex.Lock()
+	var pending []pendingImport
+	remains := mgr.pendingImports[:0]
+	for _, p := range mgr.pendingImports {
+		if p.source == newSrc.Name() {
+			pending = append(pending, p)
+		} else {
+			remains = append(remains, p)
+		}
+	}
+	mgr.pendingImports = remains
+	mgr.mutex.Unlock()
+	for _, p := range pending {
+		if err = newSrc.AddPath(p.path, p.opts...); err != nil {
+			log.Get().Warnf("Can't import %q into source %q: %v", p.path, p.source, err)
+		}
+	}
 	return nil
 }
 
@@ -103,36 +260,169 @@ func (mgr *manager) Sources() (sources []source.Source) {
 	return sources
 }
 
+func (mgr *manager) findSource(name string) source.Source {
+	for _, s := range mgr.sources {
+		if s.Name() == name {
+			return s
+		}
+	}
+	return nil
+}
+
 func (mgr *manager) AddPath(sourceName string, path string, opts ...source.PathOption) error {
+	mgr.mutex.RLock()
+	s := mgr.findSource(sourceName)
+	mgr.mutex.RUnlock()
+	if s != nil {
+		return mgr.addPath(s, path, opts...)
+	}
+	return nil
+}
+
+type pendingImport struct {
+	source string
+	path   string
+	opts   []source.PathOption
+}
+
+// importPath 导入path到指定的source, source还未添加时, 等待添加后再导入
+func (mgr *manager) importPath(sourceName string, path string, opts ...source.PathOption) error {
+	id := sourceName + ":" + path
 	mgr.mutex.Lock()
-	defer mgr.mutex.Unlock()
-	for _, s := range mgr.sources {
-		if s.Name() == sourceName {
-			return s.AddPath(path, opts...)
+	mgr.imports[id]++
+	s := mgr.findSource(sourceName)
+	if s == nil {
+		if mgr.imports[id] == 1 {
+			mgr.pendingImports = append(mgr.pendingImports, pendingImport{
+				source: sourceName, path: path, opts: opts,
+			})
+		}
+		mgr.mutex.Unlock()
+		log.Get().Debugf("Source %q not found, import %q later", sourceName, path)
+		return nil
+	}
+	mgr.mutex.Unlock()
+	return mgr.addPath(s, path, opts...)
+}
+
+// unimportPath 取消导入, 没有其他导入者时删除导入的path
+func (mgr *manager) unimportPath(sourceName string, path string) error {
+	id := sourceName + ":" + path
+	mgr.mutex.Lock()
+	mgr.imports[id]--
+	if mgr.imports[id] > 0 {
+		mgr.mutex.Unlock()
+		return nil
+	}
+	delete(mgr.imports, id)
+	remains := mgr.pendingImports[:0]
+	for _, p := range mgr.pendingImports {
+		if p.source != sourceName || p.path != path {
+			remains = append(remains, p)
 		}
 	}
+	mgr.pendingImports = remains
+	s := mgr.findSource(sourceName)
+	mgr.mutex.Unlock()
+	if r, ok := s.(importRemover); ok {
+		return r.RemoveImport(path)
+	}
 	return nil
 }
 
 func (mgr *manager) unsafeGet(key string) (val interface{}, ok bool) {
-	if val, ok = mgr.overrides[key]; ok {
+	key = mgr.normalizeKey(key)
+	if p, pinned := mgr.pins[key]; pinned {
+		return p.value, !p.deleted
+	}
+	if val, ok = mgr.unsafeConfigured(key); ok {
 		return val, ok
 	}
-	s, ok := mgr.ksMap[key]
-	if ok {
-		return s.Get(key)
+	// 旧key设置的值优先于新key的默认值
+	if val, ok = mgr.unsafeAliasGet(key); ok {
+		return val, ok
 	}
 	val, ok = mgr.defaults[key]
 	return
 }
 
+// unsafeLookup 不考虑别名查找key的值
+func (mgr *manager) unsafeLookup(key string) (val interface{}, ok bool) {
+	if p, pinned := mgr.pins[key]; pinned {
+		return p.value, !p.deleted
+	}
+	if val, ok = mgr.unsafeConfigured(key); ok {
+		return val, ok
+	}
+	val, ok = mgr.defaults[key]
+	return
+}
+
+func (mgr *manager) unsafeConfigured(key string) (val interface{}, ok bool) {
+	if p, pinned := mgr.pins[key]; pinned {
+		return p.value, !p.deleted
+	}
+	if val, ok = mgr.overrides[key]; ok {
+		return val, ok
+	}
+	if s, found := mgr.ksMap[key]; found {
+		return s.Get(key)
+	}
+	return nil, false
+}
+
+const (
+	originOverrides = "overrides"
+	originDefaults  = "defaults"
+)
+
+// pathLocator 可以查询key所在配置集合的source
+type pathLocator interface {
+	PathOf(key string) (path string, ok bool)
+}
+
+func (mgr *manager) Origin(key string) (sourceName string, path string, ok bool) {
+	key = mgr.normalizeKey(key)
+	mgr.mutex.RLock()
+	defer mgr.mutex.RUnlock()
+	if _, ok = mgr.unsafeConfigured(key); !ok {
+		for _, a := range mgr.aliases {
+			if old, matched := rename(key, a.new, a.old); matched {
+				if _, found := mgr.unsafeLookup(old); found {
+					return mgr.unsafeOrigin(old)
+				}
+			}
+		}
+	}
+	return mgr.unsafeOrigin(key)
+}
+
+func (mgr *manager) unsafeOrigin(key string) (sourceName string, path string, ok bool) {
+	if p, pinned := mgr.pins[key]; pinned {
+		return originRollback, "", !p.deleted
+	}
+	if _, ok = mgr.overrides[key]; ok {
+		return originOverrides, "", true
+	}
+	if s, found := mgr.ksMap[key]; found {
+		if l, isLocator := s.(pathLocator); isLocator {
+			path, _ = l.PathOf(key)
+		}
+		return s.Name(), path, true
+	}
+	if _, ok = mgr.defaults[key]; ok {
+		return originDefaults, "", true
+	}
+	return "", "", false
+}
+
 func (mgr *manager) Get(key string) (val interface{}, ok bool) {
 	mgr.mutex.RLock()
 	defer mgr.mutex.RUnlock()
 	if mgr.expandDisabled {
 		return mgr.unsafeGet(key)
 	}
-	v, err := mgr.expander.Expand(key)
+	v, err := mgr.expander.Expand(mgr.normalizeKey(key))
 	if err != nil {
 		log.Get().Errorf("Can't expand key %q : %v", key, err)
 		return nil, false
@@ -140,6 +430,24 @@ func (mgr *manager) Get(key string) (val interface{}, ok bool) {
 	return v, true
 }
 
+// refsOf 返回key变量替换时直接或者间接引用的key
+func (mgr *manager) refsOf(key string) (refs []string) {
+	if mgr.expandDisabled {
+		return nil
+	}
+	key = mgr.normalizeKey(key)
+	mgr.mutex.RLock()
+	defer mgr.mutex.RUnlock()
+	e := expander.New(func(k string) (interface{}, bool) {
+		if k = mgr.normalizeKey(k); k != key {
+			refs = append(refs, k)
+		}
+		return mgr.unsafeGet(k)
+	}, mgr.expandOpts...)
+	_, _ = e.Expand(key)
+	return refs
+}
+
 func (mgr *manager) All() (values map[string]interface{}) {
 	values = map[string]interface{}{}
 	mgr.mutex.RLock()
@@ -159,12 +467,32 @@ func (mgr *manager) All() (values map[string]interface{}) {
 	for k, v := range mgr.overrides {
 		values[k] = v
 	}
+	// 通过旧key设置的新key
+	for k, v := range values {
+		for _, newKey := range mgr.unsafeAliasKeys(k) {
+			if _, ok := mgr.unsafeConfigured(newKey); !ok {
+				values[newKey] = v
+			}
+		}
+	}
+	// 回滚固定的值
+	for k, p := range mgr.pins {
+		if p.deleted {
+			delete(values, k)
+		} else {
+			values[k] = p.value
+		}
+	}
 	return values
 }
 
 func (mgr *manager) Keys() (keys []string) {
 	mgr.mutex.RLock()
 	defer mgr.mutex.RUnlock()
+	return mgr.unsafeKeys()
+}
+
+func (mgr *manager) unsafeKeys() (keys []string) {
 	// 过滤重复Key
 	keysMap := map[string]bool{}
 	for k := range mgr.defaults {
@@ -177,35 +505,140 @@ func (mgr *manager) Keys() (keys []string) {
 		keysMap[k] = true
 	}
 	for k := range keysMap {
+		for _, newKey := range mgr.unsafeAliasKeys(k) {
+			keysMap[newKey] = true
+		}
+	}
+	for k, p := range mgr.pins {
+		keysMap[k] = !p.deleted
+	}
+	for k, exists := range keysMap {
+		if !exists {
+			continue
+		}
 		keys = append(keys, k)
 	}
 	return keys
 }
 
-func (mgr *manager) Set(key string, value interface{}) {
+// setLocal 修改覆盖配置或者默认配置, 生效的值变化时派发事件
+func (mgr *manager) setLocal(origin string, action source.Action, key string, modify func()) {
 	mgr.mutex.Lock()
-	defer mgr.mutex.Unlock()
-	mgr.overrides[key] = value
+	from, existed := mgr.unsafeGet(key)
+	modify()
+	to, exists := mgr.unsafeGet(key)
+	mgr.mutex.Unlock()
+	var ev *Event
+	switch {
+	case !existed && exists:
+		ev = source.NewEvent(Created, key)
+	case existed && !exists:
+		ev = source.NewEvent(Deleted, key)
+	case existed && exists && !reflect.DeepEqual(from, to):
+		ev = source.NewEvent(Updated, key)
+	default:
+		if exists && action != Deleted {
+			// 生效值没有变化, 记录被覆盖的设置
+			shadowed := &Event{Action: action, Source: origin, Key: key}
+			mgr.recordChanges(origin, []*Event{shadowed}, valuesOf(key, from, existed), valuesOf(key, to, exists))
+		}
+		return
+	}
+	ev.Source, ev.ValueFrom, ev.ValueTo = origin, from, to
+	mgr.recordChanges(origin, []*Event{ev}, valuesOf(key, from, existed), valuesOf(key, to, exists))
+	if exists {
+		mgr.warnDeprecated([]string{key})
+	}
+	mgr.dispatcher.Dispatch(mgr.withAliasEvents([]*Event{ev}, valuesOf(key, from, existed)))
+}
+
+func (mgr *manager) Set(key string, value interface{}) {
+	raw, key := key, mgr.normalizeKey(key)
+	mgr.setLocal(originOverrides, Updated, key, func() {
+		mgr.overrides[key] = value
+		mgr.setOriginal(key, raw)
+		delete(mgr.pins, key)
+	})
 }
 func (mgr *manager) Delete(key string) {
-	mgr.mutex.Lock()
-	defer mgr.mutex.Unlock()
-	delete(mgr.overrides, key)
-	delete(mgr.defaults, key)
+	key = mgr.normalizeKey(key)
+	mgr.setLocal(originOverrides, Deleted, key, func() {
+		delete(mgr.overrides, key)
+		delete(mgr.defaults, key)
+		delete(mgr.pins, key)
+	})
+}
+
+func (mgr *manager) DeleteOverride(key string) {
+	key = mgr.normalizeKey(key)
+	mgr.setLocal(originOverrides, Deleted, key, func() {
+		delete(mgr.overrides, key)
+		delete(mgr.pins, key)
+	})
 }
 
 func (mgr *manager) SetDefault(key string, value interface{}) {
-	mgr.mutex.Lock()
-	defer mgr.mutex.Unlock()
-	mgr.defaults[key] = value
+	raw, key := key, mgr.normalizeKey(key)
+	mgr.setLocal(originDefaults, Updated, key, func() {
+		mgr.defaults[key] = value
+		mgr.setOriginal(key, raw)
+	})
 }
 
 func (mgr *manager) Watch(pattern string, cb EventHandler) (watchId int64) {
-	return mgr.dispatcher.Watch(pattern, cb)
+	return mgr.dispatcher.Watch(normalizePattern(pattern, mgr.normalize), cb)
 }
 func (mgr *manager) Unwatch(watchId int64) {
 	mgr.dispatcher.Unwatch(watchId)
 }
+func (mgr *manager) Watchers() []Watcher {
+	return mgr.dispatcher.watchers()
+}
+
+func (mgr *manager) Close() (err error) {
+	mgr.closeOnce.Do(func() {
+		close(mgr.stop)
+		for _, s := range mgr.Sources() {
+			if e := s.Close(); e != nil && err == nil {
+				err = e
+			}
+		}
+		if e := mgr.history.close(); e != nil && err == nil {
+			err = e
+		}
+	})
+	return err
+}
+
+func (mgr *manager) Unmarshal(out interface{}, opts ...UnmarshalOption) error {
+	opts = append([]UnmarshalOption{
+		withUnmarshalOrigin(mgr.Origin), withUnmarshalNormalizer(mgr.normalize), withUnmarshalOriginalKey(mgr.originalKey),
+	}, opts...)
+	return unmarshal(mgr.Get, mgr.Keys(), out, opts...)
+}
+
+func (mgr *manager) Sub(prefix string) Manager {
+	return newSubManager(mgr, prefix)
+}
+
+func (mgr *manager) IntProperty(key string, def int) *IntProperty {
+	return newIntProperty(mgr, key, def)
+}
+func (mgr *manager) Int64Property(key string, def int64) *Int64Property {
+	return newInt64Property(mgr, key, def)
+}
+func (mgr *manager) FloatProperty(key string, def float64) *FloatProperty {
+	return newFloatProperty(mgr, key, def)
+}
+func (mgr *manager) StringProperty(key string, def string) *StringProperty {
+	return newStringProperty(mgr, key, def)
+}
+func (mgr *manager) BoolProperty(key string, def bool) *BoolProperty {
+	return newBoolProperty(mgr, key, def)
+}
+func (mgr *manager) DurationProperty(key string, def time.Duration) *DurationProperty {
+	return newDurationProperty(mgr, key, def)
+}
 
 // 是否在高优先级的 source 中存在该key
 func (mgr *manager) inHigherSource(key string, src source.Source) bool {
@@ -290,32 +723,61 @@ func (mgr *manager) handleUpdatedEvent(src source.Source, ev *source.Event) {
 
 func (mgr *manager) handleSourceEvents(src source.Source, events []*source.Event) {
 	_events := []*source.Event{}
+	keys := make([]string, 0, len(events))
+	for _, ev := range events {
+		ev.Key = mgr.normalizeKey(ev.Key)
+		if ev.Source == "" {
+			ev.Source = src.Name()
+		}
+		keys = append(keys, ev.Key)
+	}
+	before := mgr.effectiveValues(keys)
+	var updated []string
 	for _, ev := range events {
 		if ev.Action == Created ||
 			ev.Action == Updated {
 			mgr.handleUpdatedEvent(src, ev)
 			_events = append(_events, ev)
+			updated = append(updated, ev.Key)
 		} else if ev.Action == Deleted {
 			mgr.handleDeletedEvent(src, ev)
 			_events = append(_events, ev)
 		}
 	}
+	mgr.recordChanges(src.Name(), _events, before, mgr.effectiveValues(keys))
+	mgr.warnDeprecated(updated)
 	if len(_events) > 0 {
-		mgr.dispatcher.Dispatch(_events)
+		mgr.dispatcher.Dispatch(mgr.withAliasEvents(_events, before))
 	}
 }
 
 func newManager(opts ...Option) (Manager, error) {
 	vOpts := newOptions(opts...)
+	h, err := newHistory(vOpts.historySize, vOpts.historyFile)
+	if err != nil {
+		return nil, err
+	}
 	mgr := &manager{
-		sources:    make([]source.Source, 0),
-		ksMap:      make(map[string]source.Source),
-		overrides:  make(map[string]interface{}),
-		defaults:   make(map[string]interface{}),
-		dispatcher: newDispatcher(),
-		mutex:      sync.RWMutex{},
+		sources:      make([]source.Source, 0),
+		ksMap:        make(map[string]source.Source),
+		overrides:    make(map[string]interface{}),
+		defaults:     make(map[string]interface{}),
+		dispatcher:   newDispatcher(),
+		normalize:    vOpts.keyNormalizer,
+		deprecated:   make(map[string]*DeprecatedKey),
+		warned:       make(map[string]bool),
+		history:      h,
+		degraded:     make(map[string]bool),
+		imports:      make(map[string]int),
+		originals:    make(map[string]string),
+		pins:         make(map[string]pin),
+		snapshotDir:  vOpts.snapshotDir,
+		snapshotBoot: vOpts.snapshotBoot,
+		stop:         make(chan struct{}),
+		mutex:        sync.RWMutex{},
 	}
 	if err := mgr.init(vOpts); err != nil {
+		_ = mgr.Close()
 		return nil, err
 	}
 	return mgr, nil
@@ -0,0 +1,83 @@
+package vade
+
+import (
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+
+    "github.com/derry6/vade-go/source"
+)
+
+func TestRelaxedKeys(t *testing.T) {
+    dir, err := ioutil.TempDir("", "vade")
+    assert.NoError(t, err)
+    defer os.RemoveAll(dir)
+    file := filepath.Join(dir, "app.yaml")
+    assert.NoError(t, ioutil.WriteFile(file, []byte("db:\n  maxConns: 10\n  max-idle: 2\n  pool_size: 5\n  hosts:\n    secondary_one: b\n"), 0644))
+
+    mgr, err := NewManager(WithRelaxedKeys(), WithFileSource([]string{file}, nil))
+    assert.NoError(t, err)
+
+    v, ok := mgr.Get("db.max_conns")
+    assert.True(t, ok)
+    assert.Equal(t, 10, v)
+    v, _ = mgr.Get("DB.MaxIdle")
+    assert.Equal(t, 2, v)
+    src, path, _ := mgr.Origin("db.MAX_CONNS")
+    assert.Equal(t, "file", src)
+    assert.Equal(t, file, path)
+
+    h := &testHandler{}
+    mgr.Watch(`^db\.max_conns$`, h)
+    mgr.Set("db.maxConns", 20)
+    if assert.Len(t, h.events, 1) {
+        assert.Equal(t, "db.maxconns", h.events[0].Key)
+    }
+
+    mgr.Set("db.hosts.Primary-Host", "a")
+    var cfg struct {
+        MaxConns int `yaml:"max_conns"`
+        MaxIdle  int
+        PoolSize int
+        Hosts    map[string]string
+    }
+    assert.NoError(t, mgr.Unmarshal(&cfg, WithUnmarshalPrefix("DB"), WithUnmarshalStrict()))
+    assert.Equal(t, 20, cfg.MaxConns)
+    assert.Equal(t, 2, cfg.MaxIdle)
+    assert.Equal(t, 5, cfg.PoolSize)
+    // map的key保持原样
+    assert.Equal(t, map[string]string{"secondary_one": "b", "Primary-Host": "a"}, cfg.Hosts)
+    cfg.Hosts = nil
+    assert.NoError(t, mgr.Sub("db").Unmarshal(&cfg))
+    assert.Equal(t, map[string]string{"secondary_one": "b", "Primary-Host": "a"}, cfg.Hosts)
+
+    v, _ = mgr.Sub("Db").Get("max-conns")
+    assert.Equal(t, 20, v)
+}
+
+func TestImportAcrossSources(t *testing.T) {
+    dir, err := ioutil.TempDir("", "vade")
+    assert.NoError(t, err)
+    defer os.RemoveAll(dir)
+    file := filepath.Join(dir, "app.yaml")
+    shared := filepath.Join(dir, "shared.yaml")
+    assert.NoError(t, ioutil.WriteFile(file, []byte("vade.import: \"remote:"+shared+"\"\n"), 0644))
+    assert.NoError(t, ioutil.WriteFile(shared, []byte("db.pool: 10\n"), 0644))
+
+    mgr, err := NewManager(WithFileSource([]string{file}, nil))
+    assert.NoError(t, err)
+    // 导入的source后添加
+    remote := newTestRemote(t)
+    assert.NoError(t, mgr.AddSource(remote))
+    assert.Equal(t, []string{shared}, remote.(*source.BaseSource).Paths())
+
+    // 删除导入后, 导入的path也被删除
+    assert.NoError(t, ioutil.WriteFile(file, []byte("a: 1\n"), 0644))
+    s, err := mgr.Source("file")
+    assert.NoError(t, err)
+    assert.NoError(t, s.(*source.BaseSource).Reload(file))
+    assert.Empty(t, remote.(*source.BaseSource).Paths())
+}
@@ -1,6 +1,11 @@
 package vade
 
 import (
+    "flag"
+
+    "github.com/spf13/pflag"
+
+    "github.com/derry6/vade-go/pkg/envkey"
     "github.com/derry6/vade-go/pkg/expander"
     "github.com/derry6/vade-go/pkg/log"
     "github.com/derry6/vade-go/source"
@@ -37,18 +42,34 @@ type remoteConfig struct {
     opts   []source.Option
 }
 
+type dirConfig struct {
+    dir      string
+    required bool
+    opts     client.DirOptions
+}
+
+type flagConfig struct {
+    flagSet  *flag.FlagSet
+    pflagSet *pflag.FlagSet
+    args     []string
+}
+
 type options struct {
     // fileSource options
     withFile  bool
     requireds []string
     optionals []string
+    dirs      []dirConfig
     fileOpts  []source.Option
     // envSource options
-    withEnv bool
-    envOpts []source.Option
+    withEnv   bool
+    envOpts   []source.Option
+    dotenvs   []string
+    envMapper *envkey.Mapper
     // flagSource options
     withFlag bool
     flagOpts []source.Option
+    flags    flagConfig
 
     // remote Source
     remotes map[string]remoteConfig
@@ -57,6 +78,14 @@ type options struct {
     // expansion
     epOpts     []expander.Option
     epDisabled bool
+    // key规范化
+    keyNormalizer source.KeyNormalizer
+    // 变更记录
+    historySize int
+    historyFile string
+    // 快照
+    snapshotDir  string
+    snapshotBoot bool
 }
 
 func WithLogger(logger log.Logger) Option {
@@ -65,6 +94,48 @@ func WithLogger(logger log.Logger) Option {
     }
 }
 
+// WithKeyNormalizer 规范化所有的key, 读写, 监听和Unmarshal时key都会被规范化
+func WithKeyNormalizer(fn source.KeyNormalizer) Option {
+    return func(opts *options) {
+        opts.keyNormalizer = fn
+    }
+}
+
+// WithRelaxedKeys 宽松的key匹配, 忽略大小写, '-'和'_', 参见source.RelaxedKey。
+// Unmarshal到map时, map的key保持规范化之前的原样。
+func WithRelaxedKeys() Option {
+    return WithKeyNormalizer(source.RelaxedKey)
+}
+
+// WithHistory 保留最近size次配置变更, 默认不记录, size<=0时不记录
+func WithHistory(size int) Option {
+    return func(opts *options) {
+        opts.historySize = size
+    }
+}
+
+// WithHistoryFile 将配置变更以JSONL格式追加到文件中, 需要同时使用WithHistory, Close时关闭,
+// 变更的值没有脱敏, 文件权限为0600
+func WithHistoryFile(file string) Option {
+    return func(opts *options) {
+        opts.historyFile = file
+    }
+}
+
+// WithSnapshot 每次配置变更后将生效的配置保存到dir中, 快照没有脱敏, 文件权限为0600
+func WithSnapshot(dir string) Option {
+    return func(opts *options) {
+        opts.snapshotDir = dir
+    }
+}
+
+// WithSnapshotBoot 必须的path不可用时从快照加载该path的配置, 并在后台重试, 恢复后使用最新的配置
+func WithSnapshotBoot() Option {
+    return func(opts *options) {
+        opts.snapshotBoot = true
+    }
+}
+
 func WithFileSource(requires, optionals []string, sOpts ...source.Option) Option {
     return func(opts *options) {
         opts.withFile = true
@@ -74,12 +145,118 @@ func WithFileSource(requires, optionals []string, sOpts ...source.Option) Option
     }
 }
 
+// DirOption 目录模式的选项
+type DirOption func(opts *client.DirOptions)
+
+// WithDirPattern 只添加文件名匹配glob模式的文件, 如 "*.yaml"
+func WithDirPattern(pattern string) DirOption {
+    return func(opts *client.DirOptions) {
+        opts.Pattern = pattern
+    }
+}
+
+// WithDirRecursive 包含子目录中的文件
+func WithDirRecursive() DirOption {
+    return func(opts *client.DirOptions) {
+        opts.Recursive = true
+    }
+}
+
+// WithDirMaxFileSize 文件大小限制, 超过限制的文件会报错
+func WithDirMaxFileSize(size int64) DirOption {
+    return func(opts *client.DirOptions) {
+        opts.MaxFileSize = size
+    }
+}
+
+// WithFileDirectory 目录模式, 目录中新增的文件会被添加, 删除的文件会产生删除事件。
+// 文件源的选项使用 WithFileSource 指定。
+func WithFileDirectory(dir string, required bool, dOpts ...DirOption) Option {
+    return func(opts *options) {
+        d := dirConfig{dir: dir, required: required}
+        for _, o := range dOpts {
+            o(&d.opts)
+        }
+        opts.withFile = true
+        opts.dirs = append(opts.dirs, d)
+        if opts.fileOpts == nil {
+            opts.fileOpts = defaultFileOpts
+        }
+    }
+}
+
 func WithEnvSource(sOpts ...source.Option) Option {
     return func(opts *options) {
         opts.withEnv = true
         opts.envOpts = append(defaultEnvOpts, sOpts...)
     }
 }
+
+// EnvOption 环境变量名映射为key的选项
+type EnvOption func(m *envkey.Mapper)
+
+// WithEnvPrefix 只读取有prefix前缀的环境变量, 如 MYAPP_, 映射时去掉前缀
+func WithEnvPrefix(prefix string) EnvOption {
+    return func(m *envkey.Mapper) {
+        m.Prefix = prefix
+    }
+}
+
+// WithEnvSeparator 层级分隔符, 默认为 _, 为 __ 时单个 _ 作为名称的一部分
+func WithEnvSeparator(sep string) EnvOption {
+    return func(m *envkey.Mapper) {
+        m.Separator = sep
+    }
+}
+
+// WithEnvCamelCase 名称中的 _ 转为驼峰, 如 MAX_SIZE -> maxSize, 默认为 max_size
+func WithEnvCamelCase() EnvOption {
+    return func(m *envkey.Mapper) {
+        m.Case = envkey.CamelCase
+    }
+}
+
+// WithEnvRawKeys 保存原始的变量名
+func WithEnvRawKeys() EnvOption {
+    return func(m *envkey.Mapper) {
+        m.Keys |= envkey.RawKeys
+    }
+}
+
+// WithEnvMappedKeys 保存映射后的key
+func WithEnvMappedKeys() EnvOption {
+    return func(m *envkey.Mapper) {
+        m.Keys |= envkey.MappedKeys
+    }
+}
+
+// WithEnvMapping 设置环境变量名映射为key的规则, 同样作用于.env文件。
+// 需要通过WithEnvRawKeys或者WithEnvMappedKeys指定保存哪些key, 都没有指定时不保存任何环境变量。
+func WithEnvMapping(eOpts ...EnvOption) Option {
+    return func(opts *options) {
+        m := &envkey.Mapper{}
+        for _, o := range eOpts {
+            o(m)
+        }
+        opts.withEnv = true
+        opts.envMapper = m
+        if opts.envOpts == nil {
+            opts.envOpts = defaultEnvOpts
+        }
+    }
+}
+
+// WithDotenvFiles 从.env文件中读取环境变量, 作为真实环境变量的默认值
+func WithDotenvFiles(files ...string) Option {
+    return func(opts *options) {
+        opts.withEnv = true
+        opts.dotenvs = append(opts.dotenvs, files...)
+        if opts.envOpts == nil {
+            opts.envOpts = defaultEnvOpts
+        }
+    }
+}
+
 func WithFlagSource(sOpts ...source.Option) Option {
     return func(opts *options) {
         opts.withFlag = true
@@ -87,6 +264,24 @@ func WithFlagSource(sOpts ...source.Option) Option {
     }
 }
 
+// WithFlagSet 使用指定的FlagSet代替全局的参数, fs还没有解析时解析args, args为nil时使用os.Args[1:]。
+// fs为ContinueOnError时, 解析的错误由Init返回。
+func WithFlagSet(fs *flag.FlagSet, args []string, sOpts ...source.Option) Option {
+    return func(opts *options) {
+        WithFlagSource(sOpts...)(opts)
+        opts.flags = flagConfig{flagSet: fs, args: args}
+    }
+}
+
+// WithPFlagSet 从pflag/cobra的参数中读取配置, 只有显式设置的参数会覆盖其他配置,
+// 此时通过Flag定义的参数会被忽略
+func WithPFlagSet(fs *pflag.FlagSet, sOpts ...source.Option) Option {
+    return func(opts *options) {
+        WithFlagSource(sOpts...)(opts)
+        opts.flags = flagConfig{pflagSet: fs}
+    }
+}
+
 func WithRemoteSource(name string, config *client.Config, sOpts ...source.Option) Option {
     return func(opts *options) {
         opts.remotes[name] = remoteConfig{
@@ -113,7 +308,7 @@ func WithExpansion(pre, post string, cb expander.Handler) Option {
 
 func newOptions(opts ...Option) *options {
     mOpts := &options{
-        remotes:  make(map[string]remoteConfig),
+        remotes: make(map[string]remoteConfig),
     }
     for _, o := range opts {
         o(mOpts)
@@ -0,0 +1,140 @@
+package envkey
+
+import "strings"
+
+// Case 分隔符之间的单词转为key的方式
+type Case int
+
+const (
+	SnakeCase Case = iota // MAX_SIZE -> max_size
+	CamelCase             // MAX_SIZE -> maxSize
+)
+
+// KeyMode 保存哪些key, 需要显式指定, 为0时不保存任何key
+type KeyMode int
+
+const (
+	MappedKeys KeyMode = 1 << iota // 映射后的key, 如 FOO_BAR -> foo.bar
+	RawKeys                        // 原始的变量名, 如 FOO_BAR
+)
+
+// DefaultSeparator 默认的层级分隔符
+const DefaultSeparator = "_"
+
+// Mapper 环境变量名和配置key的映射规则
+type Mapper struct {
+	Prefix    string  // 必须的前缀, 如 MYAPP_, 映射时去掉, 不匹配的变量会被忽略
+	Separator string  // 层级分隔符, 默认为 _, 为 __ 时单个 _ 作为名称的一部分
+	Case      Case    // 分隔符之间的单词转为key的方式
+	Keys      KeyMode // 保存哪些key
+}
+
+var defaultMapper = &Mapper{}
+
+// Map 将环境变量名转为配置的key, 如 FOO_BAR -> foo.bar。
+// 以 _ 开头的变量名返回空字符串。
+func Map(name string) string {
+	key, _ := defaultMapper.Map(name)
+	return key
+}
+
+func (m *Mapper) separator() string {
+	if m == nil || m.Separator == "" {
+		return DefaultSeparator
+	}
+	return m.Separator
+}
+
+func (m *Mapper) has(mode KeyMode) bool {
+	if m == nil {
+		// 没有映射规则时, 同时保存原始的变量名和映射后的key
+		return true
+	}
+	return m.Keys&mode != 0
+}
+
+// Map 将环境变量名转为配置的key, 没有前缀或者无法映射时ok为false
+func (m *Mapper) Map(name string) (key string, ok bool) {
+	if m != nil && m.Prefix != "" {
+		if !strings.HasPrefix(name, m.Prefix) {
+			return "", false
+		}
+		name = name[len(m.Prefix):]
+	}
+	if name == "" || name[0] == '_' {
+		return "", false
+	}
+	segments := strings.Split(name, m.separator())
+	for i, seg := range segments {
+		if seg == "" {
+			return "", false
+		}
+		segments[i] = m.word(seg)
+	}
+	return strings.Join(segments, "."), true
+}
+
+func (m *Mapper) word(seg string) string {
+	seg = strings.ToLower(seg)
+	if m == nil || m.Case != CamelCase {
+		return seg
+	}
+	parts := strings.Split(seg, "_")
+	for i := 1; i < len(parts); i++ {
+		if p := parts[i]; p != "" {
+			parts[i] = strings.ToUpper(p[:1]) + p[1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// KeysOf 返回环境变量需要保存的key, m为nil时保存原始的变量名和映射后的key
+func (m *Mapper) KeysOf(name string) (keys []string) {
+	if m != nil && m.Prefix != "" && !strings.HasPrefix(name, m.Prefix) {
+		return nil
+	}
+	if m.has(RawKeys) {
+		keys = append(keys, name)
+	}
+	if m.has(MappedKeys) {
+		if key, ok := m.Map(name); ok && (key != name || !m.has(RawKeys)) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Name 将配置的key转为环境变量名, 如 db.pool.maxSize -> DB_POOL_MAX_SIZE
+func (m *Mapper) Name(key string) string {
+	key = strings.Replace(key, "]", "", -1)
+	key = strings.Replace(key, "[", ".", -1)
+	segments := strings.Split(key, ".")
+	for i, seg := range segments {
+		segments[i] = strings.ToUpper(snake(seg))
+	}
+	name := strings.Join(segments, m.separator())
+	if m != nil {
+		name = m.Prefix + name
+	}
+	return name
+}
+
+// snake 驼峰转为下划线, 如 maxSize -> max_size, HTTPServer -> http_server
+func snake(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'A' && c <= 'Z' && i > 0 {
+			prevLower := s[i-1] >= 'a' && s[i-1] <= 'z' || s[i-1] >= '0' && s[i-1] <= '9'
+			nextLower := i+1 < len(s) && s[i+1] >= 'a' && s[i+1] <= 'z'
+			if prevLower || (nextLower && s[i-1] >= 'A' && s[i-1] <= 'Z') {
+				b.WriteByte('_')
+			}
+		}
+		if c == '-' {
+			c = '_'
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,71 @@
+package envkey
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMap(t *testing.T) {
+	var tests = []struct {
+		mapper *Mapper
+		name   string
+		key    string
+		ok     bool
+	}{
+		{nil, "MY_APP_DB_URL", "my.app.db.url", true},
+		{nil, "_HIDDEN", "", false},
+		{&Mapper{Prefix: "MYAPP_"}, "MYAPP_DB_URL", "db.url", true},
+		{&Mapper{Prefix: "MYAPP_"}, "HOME", "", false},
+		{&Mapper{Separator: "__"}, "DB__POOL__MAX_SIZE", "db.pool.max_size", true},
+		{&Mapper{Separator: "__", Case: CamelCase}, "DB__POOL__MAX_SIZE", "db.pool.maxSize", true},
+		{&Mapper{Separator: "__"}, "DB____URL", "", false},
+	}
+	for _, tt := range tests {
+		key, ok := tt.mapper.Map(tt.name)
+		if key != tt.key || ok != tt.ok {
+			t.Errorf("Map(%q) = %q, %v, expect %q, %v", tt.name, key, ok, tt.key, tt.ok)
+		}
+	}
+	if key := Map("FOO_BAR"); key != "foo.bar" {
+		t.Errorf("Map(FOO_BAR) = %q", key)
+	}
+}
+
+func TestKeysOf(t *testing.T) {
+	var tests = []struct {
+		mapper *Mapper
+		name   string
+		keys   []string
+	}{
+		{nil, "FOO_BAR", []string{"FOO_BAR", "foo.bar"}},
+		{&Mapper{Keys: MappedKeys}, "FOO_BAR", []string{"foo.bar"}},
+		{&Mapper{Keys: RawKeys}, "FOO_BAR", []string{"FOO_BAR"}},
+		{&Mapper{Keys: MappedKeys}, "foo", []string{"foo"}},
+		{&Mapper{Prefix: "APP_", Keys: RawKeys}, "HOME", nil},
+		{&Mapper{Prefix: "APP_"}, "APP_FOO", nil},
+		{&Mapper{}, "FOO_BAR", nil},
+	}
+	for _, tt := range tests {
+		if keys := tt.mapper.KeysOf(tt.name); !reflect.DeepEqual(keys, tt.keys) {
+			t.Errorf("KeysOf(%q) = %v, expect %v", tt.name, keys, tt.keys)
+		}
+	}
+}
+
+func TestName(t *testing.T) {
+	var tests = []struct {
+		mapper *Mapper
+		key    string
+		name   string
+	}{
+		{nil, "db.pool.maxSize", "DB_POOL_MAX_SIZE"},
+		{nil, "http.HTTPServer", "HTTP_HTTP_SERVER"},
+		{nil, "servers[0].host", "SERVERS_0_HOST"},
+		{&Mapper{Prefix: "MYAPP_", Separator: "__"}, "db.pool.maxSize", "MYAPP_DB__POOL__MAX_SIZE"},
+	}
+	for _, tt := range tests {
+		if name := tt.mapper.Name(tt.key); name != tt.name {
+			t.Errorf("Name(%q) = %q, expect %q", tt.key, name, tt.name)
+		}
+	}
+}
@@ -5,44 +5,133 @@ import (
     "reflect"
 )
 
-var (
-    _flatter = &flatter{}
-)
-type flatter struct{}
+// MergeKey YAML的合并键
+const MergeKey = "<<"
 
-func (f *flatter) do(curKey string, value interface{}, dst map[string]interface{}) (err error) {
-    var nextMap map[string]interface{}
-    switch value.(type) {
+type options struct {
+    useReflect bool
+    keepEmpty  bool
+}
+
+type Option func(opts *options)
+
+// WithReflect 使用反射展开
+func WithReflect() Option {
+    return func(opts *options) { opts.useReflect = true }
+}
+
+// WithEmpty 保留空的map和列表, 空map的值为map[string]interface{}{}, 空列表的值为[]interface{}{}
+func WithEmpty() Option {
+    return func(opts *options) { opts.keepEmpty = true }
+}
+
+type flatter struct {
+    keepEmpty bool
+}
+
+// keyString 将标量的map key转为字符串
+func keyString(k interface{}) (string, error) {
+    switch key := k.(type) {
+    case string:
+        return key, nil
+    case nil:
+        return "null", nil
+    case bool, int, int8, int16, int32, int64,
+        uint, uint8, uint16, uint32, uint64, float32, float64:
+        return fmt.Sprint(key), nil
+    }
+    return "", fmt.Errorf("map key '%#v' is not scalar", k)
+}
+
+// toMap 将map转为map[string]interface{}, 并处理合并键
+func (f *flatter) toMap(value interface{}) (m map[string]interface{}, ok bool, err error) {
+    var merges interface{}
+    switch x := value.(type) {
     case map[string]interface{}: // json
-        nextMap = value.(map[string]interface{})
+        if _, has := x[MergeKey]; !has {
+            return x, true, nil
+        }
+        m = make(map[string]interface{}, len(x))
+        for k, v := range x {
+            m[k] = v
+        }
     case map[interface{}]interface{}: // yaml
-        nextMap = make(map[string]interface{})
-        for k, v := range value.(map[interface{}]interface{}) {
-            if key, ok := k.(string); ok {
-                nextMap[key] = v
-            } else {
-                return fmt.Errorf("map key '%#v' is not string", k)
+        m = make(map[string]interface{}, len(x))
+        for k, v := range x {
+            key, err := keyString(k)
+            if err != nil {
+                return nil, true, err
             }
+            m[key] = v
         }
     case map[string]string:
-        nextMap = make(map[string]interface{})
-        for k, v := range value.(map[string]string) {
-            nextMap[k] = v
+        m = make(map[string]interface{}, len(x))
+        for k, v := range x {
+            m[k] = v
         }
+        return m, true, nil
+    default:
+        return nil, false, nil
+    }
+    merges, hasMerge := m[MergeKey]
+    if !hasMerge {
+        return m, true, nil
+    }
+    delete(m, MergeKey)
+    // 显式的key优先, 列表中靠前的map优先
+    sources, isList := merges.([]interface{})
+    if !isList {
+        sources = []interface{}{merges}
+    }
+    explicit := make(map[string]bool, len(m))
+    for k := range m {
+        explicit[k] = true
+    }
+    for _, src := range sources {
+        sm, isMap, err := f.toMap(src)
+        if err != nil {
+            return nil, true, err
+        }
+        if !isMap {
+            return nil, true, fmt.Errorf("merge value '%#v' is not map", src)
+        }
+        for k, v := range sm {
+            if _, exists := m[k]; !exists && !explicit[k] {
+                m[k] = v
+            }
+        }
+    }
+    return m, true, nil
+}
+
+func (f *flatter) do(curKey string, value interface{}, dst map[string]interface{}) (err error) {
+    nextMap, isMap, err := f.toMap(value)
+    if err != nil {
+        return err
+    }
+    if isMap {
+        if len(nextMap) == 0 && f.keepEmpty && curKey != "" {
+            dst[curKey] = map[string]interface{}{}
+            return nil
+        }
+        return f.doMap(curKey, nextMap, dst)
+    }
+    switch x := value.(type) {
     case []byte:
-        dst[curKey] = string(value.([]byte))
+        dst[curKey] = string(x)
     case []interface{}:
-        arr := value.([]interface{})
-        if err = f.doArray(curKey, arr, dst); err != nil {
-            return err
-        }
+        return f.doArray(curKey, x, dst)
     default:
         dst[curKey] = value
     }
-    return f.doMap(curKey, nextMap, dst)
+    return nil
 }
 
 func (f *flatter) doArray(curKey string, value []interface{}, out map[string]interface{}) (err error) {
+    if len(value) == 0 && f.keepEmpty && curKey != "" {
+        out[curKey] = []interface{}{}
+        return nil
+    }
     subKey := ""
     for i := 0; i < len(value); i++ {
         subKey = fmt.Sprintf("%s[%d]", curKey, i)
@@ -67,8 +156,25 @@ func (f *flatter) doMap(parent string, src, dst map[string]interface{}) (err err
 // Flatten 多级的map转为properties形式
 func Flatten(src, dst map[string]interface{}, prefix string, useReflect bool) (err error) {
     if useReflect {
-        vIn := reflect.ValueOf(src)
-        return _reflectFlatter.do(prefix, vIn, dst)
+        return FlattenWith(src, dst, prefix, WithReflect())
+    }
+    return FlattenWith(src, dst, prefix)
+}
+
+// FlattenWith 使用选项将多级的map转为properties形式
+func FlattenWith(src, dst map[string]interface{}, prefix string, opts ...Option) (err error) {
+    o := &options{}
+    for _, opt := range opts {
+        opt(o)
+    }
+    if o.useReflect {
+        rf := &reflectFlatter{keepEmpty: o.keepEmpty}
+        return rf.doMap(prefix, reflect.ValueOf(src), dst)
+    }
+    f := &flatter{keepEmpty: o.keepEmpty}
+    m, _, err := f.toMap(src)
+    if err != nil {
+        return err
     }
-    return _flatter.doMap(prefix, src, dst)
+    return f.doMap(prefix, m, dst)
 }
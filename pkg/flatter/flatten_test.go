@@ -38,6 +38,78 @@ func TestFlattenReflect(t *testing.T) {
     }
 }
 
+func TestFlattenScalarKeys(t *testing.T) {
+    src := map[string]interface{}{
+        "codes": map[interface{}]interface{}{404: "not found", true: "yes", 1.5: "f"},
+    }
+    for _, useReflect := range []bool{false, true} {
+        out := map[string]interface{}{}
+        if err := Flatten(src, out, "", useReflect); err != nil {
+            t.Fatal(err)
+        }
+        if out["codes.404"] != "not found" || out["codes.true"] != "yes" || out["codes.1.5"] != "f" {
+            t.Errorf("reflect=%v, out=%v", useReflect, out)
+        }
+    }
+}
+
+func TestFlattenMergeKey(t *testing.T) {
+    base := map[interface{}]interface{}{"host": "localhost", "port": 80}
+    src := map[string]interface{}{
+        "dev": map[interface{}]interface{}{
+            "<<":   base,
+            "port": 8080,
+        },
+        "prod": map[interface{}]interface{}{
+            "<<": []interface{}{
+                map[interface{}]interface{}{"host": "prod"},
+                base,
+            },
+        },
+    }
+    for _, useReflect := range []bool{false, true} {
+        out := map[string]interface{}{}
+        if err := Flatten(src, out, "", useReflect); err != nil {
+            t.Fatal(err)
+        }
+        if out["dev.host"] != "localhost" || out["dev.port"] != 8080 {
+            t.Errorf("reflect=%v, dev=%v", useReflect, out)
+        }
+        if out["prod.host"] != "prod" || out["prod.port"] != 80 {
+            t.Errorf("reflect=%v, prod=%v", useReflect, out)
+        }
+        if _, ok := out["dev.<<"]; ok {
+            t.Errorf("reflect=%v, merge key not resolved", useReflect)
+        }
+    }
+}
+
+func TestFlattenEmpty(t *testing.T) {
+    src := map[string]interface{}{
+        "m": map[interface{}]interface{}{},
+        "l": []interface{}{},
+    }
+    out := map[string]interface{}{}
+    if err := Flatten(src, out, "", false); err != nil {
+        t.Fatal(err)
+    }
+    if _, ok := out["m"]; ok {
+        t.Errorf("empty map should be dropped by default")
+    }
+    for _, opts := range [][]Option{{WithEmpty()}, {WithEmpty(), WithReflect()}} {
+        out = map[string]interface{}{}
+        if err := FlattenWith(src, out, "", opts...); err != nil {
+            t.Fatal(err)
+        }
+        if m, ok := out["m"].(map[string]interface{}); !ok || len(m) != 0 {
+            t.Errorf("empty map not preserved: %v", out)
+        }
+        if l, ok := out["l"].([]interface{}); !ok || len(l) != 0 {
+            t.Errorf("empty list not preserved: %v", out)
+        }
+    }
+}
+
 func BenchmarkFlatten(b *testing.B) {
     out := map[string]interface{}{}
     b.Run("Normal", func(b *testing.B) {
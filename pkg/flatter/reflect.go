@@ -5,30 +5,93 @@ import (
     "reflect"
 )
 
-var (
-    _reflectFlatter = &reflectFlatter{}
-)
+type reflectFlatter struct {
+    keepEmpty bool
+}
 
-type reflectFlatter struct {}
+func (rf *reflectFlatter) keyOf(rkv reflect.Value) (string, error) {
+    if rkv.Kind() == reflect.Interface {
+        if rkv.IsNil() {
+            return keyString(nil)
+        }
+        rkv = rkv.Elem()
+    }
+    switch rkv.Kind() {
+    case reflect.String:
+        return rkv.String(), nil
+    case reflect.Bool,
+        reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+        reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+        reflect.Float32, reflect.Float64:
+        return fmt.Sprint(rkv.Interface()), nil
+    }
+    return "", fmt.Errorf("key of map must be scalar, but %v", rkv.Kind())
+}
 
-func (rf *reflectFlatter) doMap(prefix string, raw reflect.Value, out map[string]interface{}) (err error) {
-    var key string
+// entries 返回map的所有元素, 合并键引用的map中被显式key覆盖的元素会被忽略
+func (rf *reflectFlatter) entries(raw reflect.Value, entries map[string]reflect.Value) (err error) {
+    var merges []reflect.Value
+    explicit := map[string]bool{}
     for iter := raw.MapRange(); iter.Next(); {
-        rkv := iter.Key()
-        switch rkv.Kind() {
-        case reflect.String:
-            key = rkv.String()
-        case reflect.Interface:
-            elem := rkv.Elem()
-            if elem.Kind() != reflect.String {
-                return fmt.Errorf("key of map must string, but %v", elem.Kind())
+        key, err := rf.keyOf(iter.Key())
+        if err != nil {
+            return err
+        }
+        if key == MergeKey {
+            merges = append(merges, iter.Value())
+            continue
+        }
+        explicit[key] = true
+        entries[key] = iter.Value()
+    }
+    for _, merge := range merges {
+        for merge.Kind() == reflect.Interface {
+            merge = merge.Elem()
+        }
+        var sources []reflect.Value
+        switch merge.Kind() {
+        case reflect.Map:
+            sources = append(sources, merge)
+        case reflect.Slice:
+            for i := 0; i < merge.Len(); i++ {
+                src := merge.Index(i)
+                for src.Kind() == reflect.Interface {
+                    src = src.Elem()
+                }
+                if src.Kind() != reflect.Map {
+                    return fmt.Errorf("merge value must be map, but %v", src.Kind())
+                }
+                sources = append(sources, src)
             }
-            key = elem.String()
         default:
-            return fmt.Errorf("key of map must string, but %v", rkv.Kind())
+            return fmt.Errorf("merge value must be map, but %v", merge.Kind())
         }
-        err = rf.do(mergeKey(prefix, key), iter.Value(), out)
-        if err != nil {
+        for _, src := range sources {
+            sub := map[string]reflect.Value{}
+            if err = rf.entries(src, sub); err != nil {
+                return err
+            }
+            for k, v := range sub {
+                if _, exists := entries[k]; !exists && !explicit[k] {
+                    entries[k] = v
+                }
+            }
+        }
+    }
+    return nil
+}
+
+func (rf *reflectFlatter) doMap(prefix string, raw reflect.Value, out map[string]interface{}) (err error) {
+    entries := map[string]reflect.Value{}
+    if err = rf.entries(raw, entries); err != nil {
+        return err
+    }
+    if len(entries) == 0 && rf.keepEmpty && prefix != "" {
+        out[prefix] = map[string]interface{}{}
+        return nil
+    }
+    for key, value := range entries {
+        if err = rf.do(mergeKey(prefix, key), value, out); err != nil {
             return
         }
     }
@@ -40,6 +103,10 @@ func (rf *reflectFlatter) doArray(prefix string, raw reflect.Value, out map[stri
         subKey string
     )
     nums := raw.Len()
+    if nums == 0 && rf.keepEmpty && prefix != "" {
+        out[prefix] = []interface{}{}
+        return nil
+    }
     for i := 0; i < nums; i++ {
         subKey = fmt.Sprintf("%s[%d]", prefix, i)
         item := raw.Index(i)
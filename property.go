@@ -0,0 +1,218 @@
+package vade
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+// property 缓存key当前解析后的值, key或者${...}引用的key变化时由dispatcher更新
+type property struct {
+	mgr       Manager
+	key       string
+	def       interface{}
+	decode    func(v interface{}) (interface{}, error)
+	value     atomic.Value // *propertyValue
+	watchId   int64
+	refs      []string // 监听的引用的key
+	closed    bool
+	mutex     sync.Mutex
+	callbacks []func(old, new interface{})
+}
+
+type propertyValue struct {
+	value interface{}
+	err   error
+}
+
+func newProperty(mgr Manager, key string, def interface{}, decode func(v interface{}) (interface{}, error)) *property {
+	p := &property{mgr: mgr, key: key, def: def, decode: decode}
+	p.value.Store(&propertyValue{value: def})
+	p.watchId = mgr.Watch(watchPattern(key, nil), p)
+	p.refresh()
+	return p
+}
+
+// watchPattern 匹配key以及引用的key
+func watchPattern(key string, refs []string) string {
+	keys := make([]string, 0, len(refs)+1)
+	for _, k := range append([]string{key}, refs...) {
+		keys = append(keys, regexp.QuoteMeta(k))
+	}
+	return "^(" + strings.Join(keys, "|") + ")$"
+}
+
+// watchRefs 引用的key变化时重新监听
+func (p *property) watchRefs() {
+	m, ok := p.mgr.(*manager)
+	if !ok {
+		return
+	}
+	refs := m.refsOf(p.key)
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.closed || reflect.DeepEqual(refs, p.refs) {
+		return
+	}
+	last := p.watchId
+	p.refs = refs
+	p.watchId = p.mgr.Watch(watchPattern(p.key, refs), p)
+	p.mgr.Unwatch(last)
+}
+
+func (p *property) load() interface{} {
+	return p.value.Load().(*propertyValue).value
+}
+
+// refresh 重新获取并解析key的值, 解析失败时保留上一次的值
+func (p *property) refresh() {
+	p.mutex.Lock()
+	last := p.value.Load().(*propertyValue)
+	next := &propertyValue{value: p.def}
+	if v, ok := p.mgr.Get(p.key); ok {
+		if x, err := p.decode(v); err != nil {
+			next.value, next.err = last.value, err
+		} else {
+			next.value = x
+		}
+	}
+	p.value.Store(next)
+	callbacks := p.callbacks
+	p.mutex.Unlock()
+	p.watchRefs()
+	if next.value == last.value {
+		return
+	}
+	for _, cb := range callbacks {
+		cb(last.value, next.value)
+	}
+}
+
+func (p *property) onChange(cb func(old, new interface{})) {
+	p.mutex.Lock()
+	p.callbacks = append(p.callbacks, cb)
+	p.mutex.Unlock()
+}
+
+func (p *property) OnPropertyChange(events []*Event) { p.refresh() }
+
+// Key 返回属性的key
+func (p *property) Key() string { return p.key }
+
+// Err 返回最后一次解析的错误
+func (p *property) Err() error { return p.value.Load().(*propertyValue).err }
+
+// Close 停止更新属性
+func (p *property) Close() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.closed = true
+	p.mgr.Unwatch(p.watchId)
+}
+
+// IntProperty int类型的动态属性
+type IntProperty struct{ *property }
+
+// Get 获取当前值
+func (p *IntProperty) Get() int { return p.load().(int) }
+
+// OnChange 值变化时回调
+func (p *IntProperty) OnChange(cb func(old, new int)) {
+	p.onChange(func(old, new interface{}) { cb(old.(int), new.(int)) })
+}
+
+// Int64Property int64类型的动态属性
+type Int64Property struct{ *property }
+
+// Get 获取当前值
+func (p *Int64Property) Get() int64 { return p.load().(int64) }
+
+// OnChange 值变化时回调
+func (p *Int64Property) OnChange(cb func(old, new int64)) {
+	p.onChange(func(old, new interface{}) { cb(old.(int64), new.(int64)) })
+}
+
+// FloatProperty float64类型的动态属性
+type FloatProperty struct{ *property }
+
+// Get 获取当前值
+func (p *FloatProperty) Get() float64 { return p.load().(float64) }
+
+// OnChange 值变化时回调
+func (p *FloatProperty) OnChange(cb func(old, new float64)) {
+	p.onChange(func(old, new interface{}) { cb(old.(float64), new.(float64)) })
+}
+
+// StringProperty string类型的动态属性
+type StringProperty struct{ *property }
+
+// Get 获取当前值
+func (p *StringProperty) Get() string { return p.load().(string) }
+
+// OnChange 值变化时回调
+func (p *StringProperty) OnChange(cb func(old, new string)) {
+	p.onChange(func(old, new interface{}) { cb(old.(string), new.(string)) })
+}
+
+// BoolProperty bool类型的动态属性
+type BoolProperty struct{ *property }
+
+// Get 获取当前值
+func (p *BoolProperty) Get() bool { return p.load().(bool) }
+
+// OnChange 值变化时回调
+func (p *BoolProperty) OnChange(cb func(old, new bool)) {
+	p.onChange(func(old, new interface{}) { cb(old.(bool), new.(bool)) })
+}
+
+// DurationProperty time.Duration类型的动态属性
+type DurationProperty struct{ *property }
+
+// Get 获取当前值
+func (p *DurationProperty) Get() time.Duration { return p.load().(time.Duration) }
+
+// OnChange 值变化时回调
+func (p *DurationProperty) OnChange(cb func(old, new time.Duration)) {
+	p.onChange(func(old, new interface{}) { cb(old.(time.Duration), new.(time.Duration)) })
+}
+
+func newIntProperty(mgr Manager, key string, def int) *IntProperty {
+	return &IntProperty{newProperty(mgr, key, def, func(v interface{}) (interface{}, error) {
+		return cast.ToIntE(v)
+	})}
+}
+
+func newInt64Property(mgr Manager, key string, def int64) *Int64Property {
+	return &Int64Property{newProperty(mgr, key, def, func(v interface{}) (interface{}, error) {
+		return cast.ToInt64E(v)
+	})}
+}
+
+func newFloatProperty(mgr Manager, key string, def float64) *FloatProperty {
+	return &FloatProperty{newProperty(mgr, key, def, func(v interface{}) (interface{}, error) {
+		return cast.ToFloat64E(v)
+	})}
+}
+
+func newStringProperty(mgr Manager, key string, def string) *StringProperty {
+	return &StringProperty{newProperty(mgr, key, def, func(v interface{}) (interface{}, error) {
+		return cast.ToStringE(v)
+	})}
+}
+
+func newBoolProperty(mgr Manager, key string, def bool) *BoolProperty {
+	return &BoolProperty{newProperty(mgr, key, def, func(v interface{}) (interface{}, error) {
+		return cast.ToBoolE(v)
+	})}
+}
+
+func newDurationProperty(mgr Manager, key string, def time.Duration) *DurationProperty {
+	return &DurationProperty{newProperty(mgr, key, def, func(v interface{}) (interface{}, error) {
+		return cast.ToDurationE(v)
+	})}
+}
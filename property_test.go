@@ -0,0 +1,66 @@
+package vade
+
+import (
+    "testing"
+    "time"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestIntProperty(t *testing.T) {
+    mgr, _ := NewManager()
+    mgr.Set("rate.limit", 10)
+    p := mgr.IntProperty("rate.limit", 100)
+    defer p.Close()
+    assert.Equal(t, 10, p.Get())
+
+    var changes [][2]int
+    p.OnChange(func(old, new int) { changes = append(changes, [2]int{old, new}) })
+    mgr.Set("rate.limit", "20")
+    assert.Equal(t, 20, p.Get())
+    assert.NoError(t, p.Err())
+
+    // 解析失败时保留上一次的值
+    mgr.Set("rate.limit", "abc")
+    assert.Equal(t, 20, p.Get())
+    assert.Error(t, p.Err())
+
+    mgr.Delete("rate.limit")
+    assert.Equal(t, 100, p.Get())
+    assert.NoError(t, p.Err())
+    assert.Equal(t, [][2]int{{10, 20}, {20, 100}}, changes)
+}
+
+func TestSubProperty(t *testing.T) {
+    mgr, _ := NewManager()
+    p := mgr.Sub("cache").DurationProperty("ttl", time.Second)
+    defer p.Close()
+    assert.Equal(t, time.Second, p.Get())
+    mgr.SetDefault("cache.ttl", "3s")
+    assert.Equal(t, 3*time.Second, p.Get())
+    assert.Equal(t, "cache.ttl", p.Key())
+}
+
+func TestPropertyRefs(t *testing.T) {
+    mgr, _ := NewManager()
+    mgr.Set("port", 80)
+    mgr.Set("addr", "${host}:${port}")
+    mgr.Set("host", "${domain}")
+    mgr.Set("domain", "localhost")
+    p := mgr.StringProperty("addr", "")
+    defer p.Close()
+    assert.Equal(t, "localhost:80", p.Get())
+
+    // 引用的key变化时更新
+    mgr.Set("port", 8080)
+    assert.Equal(t, "localhost:8080", p.Get())
+    mgr.Set("domain", "example.com")
+    assert.Equal(t, "example.com:8080", p.Get())
+
+    // 引用变化后监听新的key
+    mgr.Set("host", "${ip}")
+    mgr.Set("ip", "127.0.0.1")
+    assert.Equal(t, "127.0.0.1:8080", p.Get())
+    mgr.Set("domain", "other.com")
+    assert.Equal(t, "127.0.0.1:8080", p.Get())
+}
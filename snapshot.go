@@ -0,0 +1,424 @@
+package vade
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	pkgerrs "github.com/pkg/errors"
+
+	"github.com/derry6/vade-go/pkg/log"
+	"github.com/derry6/vade-go/source"
+	"github.com/derry6/vade-go/source/client"
+)
+
+const (
+	// SnapshotFile 快照在CacheDir中的文件名
+	SnapshotFile = "vade-snapshot.json"
+
+	snapshotSourcePrefix = "snapshot:"
+)
+
+// snapshotRetryInterval 从快照启动后, 重新添加path的初始间隔, 每次失败后加倍, 最多为12倍
+var snapshotRetryInterval = 5 * time.Second
+
+const snapshotMaxRetryFactor = 12
+
+// SnapshotEntry 快照中的单个配置以及它的来源
+type SnapshotEntry struct {
+	Key    string      `json:"key"`
+	Value  interface{} `json:"value"`
+	Source string      `json:"source,omitempty"`
+	Path   string      `json:"path,omitempty"`
+}
+
+// Snapshot 最后一次生效的完整配置
+type Snapshot struct {
+	Time    time.Time       `json:"time"`
+	Entries []SnapshotEntry `json:"entries"`
+}
+
+// ReadSnapshot 读取dir中的快照
+func ReadSnapshot(dir string) (*Snapshot, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, SnapshotFile))
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	snap := &Snapshot{}
+	if err = dec.Decode(snap); err != nil {
+		return nil, pkgerrs.Wrap(err, "decode snapshot")
+	}
+	for i := range snap.Entries {
+		snap.Entries[i].Value = fromJSONNumber(snap.Entries[i].Value)
+	}
+	return snap, nil
+}
+
+// fromJSONNumber 将json.Number转换为int或者float64
+func fromJSONNumber(v interface{}) interface{} {
+	switch x := v.(type) {
+	case json.Number:
+		if n, err := x.Int64(); err == nil && int64(int(n)) == n {
+			return int(n)
+		} else if err == nil {
+			return n
+		}
+		f, _ := x.Float64()
+		return f
+	case map[string]interface{}:
+		for k, e := range x {
+			x[k] = fromJSONNumber(e)
+		}
+	case []interface{}:
+		for i, e := range x {
+			x[i] = fromJSONNumber(e)
+		}
+	}
+	return v
+}
+
+// writeSnapshot 快照没有脱敏, 包含环境变量和远程配置中的密钥, 只允许当前用户读写
+func writeSnapshot(dir string, snap *Snapshot) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return err
+	}
+	file := filepath.Join(dir, SnapshotFile)
+	tmp := file + ".tmp"
+	// 已经存在的文件不会修改权限
+	_ = os.Remove(tmp)
+	if err = ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// saveSnapshot 保存当前生效的配置, 从快照加载的配置保留原始的来源
+func (mgr *manager) saveSnapshot() {
+	if mgr.snapshotDir == "" {
+		return
+	}
+	entries := map[string]SnapshotEntry{}
+	mgr.mutex.RLock()
+	for k, v := range mgr.defaults {
+		entries[k] = SnapshotEntry{Key: k, Value: v, Source: originDefaults}
+	}
+	for k, s := range mgr.ksMap {
+		v, ok := s.Get(k)
+		if !ok {
+			continue
+		}
+		e := SnapshotEntry{Key: k, Value: v, Source: s.Name()}
+		if ss, isSnapshot := s.(*snapshotSource); isSnapshot {
+			e = ss.entryOf(k)
+		} else if l, isLocator := s.(pathLocator); isLocator {
+			e.Path, _ = l.PathOf(k)
+		}
+		entries[k] = e
+	}
+	for k, v := range mgr.overrides {
+		entries[k] = SnapshotEntry{Key: k, Value: v, Source: originOverrides}
+	}
+	for k, p := range mgr.pins {
+		if p.deleted {
+			delete(entries, k)
+		} else {
+			entries[k] = SnapshotEntry{Key: k, Value: p.value, Source: originRollback}
+		}
+	}
+	mgr.mutex.RUnlock()
+
+	snap := &Snapshot{Time: time.Now(), Entries: make([]SnapshotEntry, 0, len(entries))}
+	for _, e := range entries {
+		snap.Entries = append(snap.Entries, e)
+	}
+	sort.Slice(snap.Entries, func(i, j int) bool { return snap.Entries[i].Key < snap.Entries[j].Key })
+	mgr.snapshotMutex.Lock()
+	defer mgr.snapshotMutex.Unlock()
+	if err := writeSnapshot(mgr.snapshotDir, snap); err != nil {
+		log.Get().Errorf("Can't save snapshot to %q: %v", mgr.snapshotDir, err)
+	}
+}
+
+// addPath 添加path, 必须的path添加失败时, 如果允许则从快照加载该path的配置
+func (mgr *manager) addPath(s source.Source, path string, opts ...source.PathOption) error {
+	err := s.AddPath(path, opts...)
+	if err == nil || !mgr.snapshotBoot || mgr.snapshotDir == "" {
+		return err
+	}
+	snap, e2 := ReadSnapshot(mgr.snapshotDir)
+	if e2 != nil {
+		log.Get().Warnf("Can't read snapshot from %q: %v", mgr.snapshotDir, e2)
+		return err
+	}
+	var entries []SnapshotEntry
+	for _, e := range snap.Entries {
+		if e.Source == s.Name() && e.Path == path {
+			entries = append(entries, e)
+		}
+	}
+	if len(entries) == 0 {
+		return err
+	}
+	if e2 = mgr.loadSnapshot(s, path, entries); e2 != nil {
+		return err
+	}
+	log.Get().Warnf("Path %q of source %q is unavailable, boot from snapshot of %v: %v",
+		path, s.Name(), snap.Time, err)
+	go mgr.recoverPath(s, path, opts, snapshotRetryInterval)
+	return nil
+}
+
+// addMissing 必须的文件或目录不可用时, 从快照加载root以及root中的path
+func (mgr *manager) addMissing(s source.Source, root string, err error, opts ...source.PathOption) error {
+	if !mgr.snapshotBoot || mgr.snapshotDir == "" {
+		return err
+	}
+	snap, e2 := ReadSnapshot(mgr.snapshotDir)
+	if e2 != nil {
+		return err
+	}
+	root = filepath.Clean(root)
+	seen := map[string]bool{}
+	var paths []string
+	for _, e := range snap.Entries {
+		if e.Source != s.Name() || seen[e.Path] {
+			continue
+		}
+		if p := filepath.Clean(e.Path); p == root || strings.HasPrefix(p, root+string(filepath.Separator)) {
+			seen[e.Path] = true
+			paths = append(paths, e.Path)
+		}
+	}
+	if len(paths) == 0 {
+		return err
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		if e2 = mgr.addPath(s, p, opts...); e2 != nil {
+			return e2
+		}
+	}
+	return nil
+}
+
+func (mgr *manager) loadSnapshot(s source.Source, path string, entries []SnapshotEntry) error {
+	name := snapshotSourcePrefix + s.Name()
+	mgr.mutex.Lock()
+	mgr.degraded[s.Name()+":"+path] = true
+	src, _ := mgr.findSource(name).(*snapshotSource)
+	mgr.mutex.Unlock()
+	if src != nil {
+		src.add(entries)
+		return nil
+	}
+	// 快照的优先级低于原来的source, 恢复后原来的配置生效
+	src = &snapshotSource{name: name, priority: s.Priority() - 1, entries: map[string]SnapshotEntry{}}
+	src.add(entries)
+	return mgr.AddSource(src)
+}
+
+// recoverPath 重新添加path, 成功后删除快照中该path的配置, manager关闭时停止
+func (mgr *manager) recoverPath(s source.Source, path string, opts []source.PathOption, retry time.Duration) {
+	interval := retry
+	for {
+		timer := time.NewTimer(interval)
+		select {
+		case <-mgr.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		err := s.AddPath(path, opts...)
+		if err == nil {
+			break
+		}
+		log.Get().Debugf("Path %q of source %q is still unavailable: %v", path, s.Name(), err)
+		if interval *= 2; interval > retry*snapshotMaxRetryFactor {
+			interval = retry * snapshotMaxRetryFactor
+		}
+	}
+	mgr.mutex.Lock()
+	delete(mgr.degraded, s.Name()+":"+path)
+	src, _ := mgr.findSource(snapshotSourcePrefix + s.Name()).(*snapshotSource)
+	var replaced []string
+	if src != nil {
+		for _, k := range src.keysOf(path) {
+			// 已经从source加载的key直接切换, 不产生删除事件
+			if _, ok := s.Get(k); ok {
+				if mgr.ksMap[k] == src {
+					mgr.ksMap[k] = s
+				}
+				replaced = append(replaced, k)
+			}
+		}
+	}
+	mgr.mutex.Unlock()
+	if src != nil {
+		src.remove(path, replaced)
+	}
+	log.Get().Infof("Path %q of source %q is recovered from snapshot", path, s.Name())
+}
+
+func (mgr *manager) Degraded() (paths []string) {
+	mgr.mutex.RLock()
+	defer mgr.mutex.RUnlock()
+	for p := range mgr.degraded {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// snapshotSource 保存从快照加载的配置, 代替不可用的source
+type snapshotSource struct {
+	name     string
+	priority int
+	entries  map[string]SnapshotEntry
+	callback func([]*Event)
+	mutex    sync.RWMutex
+}
+
+var (
+	_ source.Source      = (*snapshotSource)(nil)
+	_ source.PathRemover = (*snapshotSource)(nil)
+)
+
+func (s *snapshotSource) Close() error          { return nil }
+func (s *snapshotSource) Name() string          { return s.name }
+func (s *snapshotSource) Client() client.Client { return nil }
+func (s *snapshotSource) Priority() int         { return s.priority }
+
+func (s *snapshotSource) Keys() (keys []string) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for k := range s.entries {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func (s *snapshotSource) All() (values map[string]interface{}) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	values = make(map[string]interface{}, len(s.entries))
+	for k, e := range s.entries {
+		values[k] = e.Value
+	}
+	return values
+}
+
+func (s *snapshotSource) Get(key string) (value interface{}, ok bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	e, ok := s.entries[key]
+	return e.Value, ok
+}
+
+func (s *snapshotSource) Set(key string, value interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if e, ok := s.entries[key]; ok {
+		e.Value = value
+		s.entries[key] = e
+	}
+}
+
+func (s *snapshotSource) AddPath(path string, opts ...source.PathOption) error {
+	return pkgerrs.New("snapshot source does not support adding path")
+}
+
+// RemovePath path为原始的source:path
+func (s *snapshotSource) RemovePath(path string) error {
+	i := strings.Index(path, ":")
+	if i < 0 {
+		return pkgerrs.New("path not found")
+	}
+	s.remove(path[i+1:], nil)
+	return nil
+}
+
+func (s *snapshotSource) OnEvents(cb func([]*Event)) {
+	s.callback = cb
+}
+
+// PathOf 返回快照中记录的原始path
+func (s *snapshotSource) PathOf(key string) (path string, ok bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	e, ok := s.entries[key]
+	return e.Path, ok
+}
+
+func (s *snapshotSource) entryOf(key string) SnapshotEntry {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.entries[key]
+}
+
+func (s *snapshotSource) keysOf(path string) (keys []string) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for k, e := range s.entries {
+		if e.Path == path {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func (s *snapshotSource) add(entries []SnapshotEntry) {
+	var events []*Event
+	s.mutex.Lock()
+	for _, e := range entries {
+		ev := source.NewEvent(Created, e.Key)
+		if last, ok := s.entries[e.Key]; ok {
+			ev.Action, ev.ValueFrom = Updated, last.Value
+		}
+		ev.Source, ev.Path, ev.ValueTo = s.name, e.Path, e.Value
+		s.entries[e.Key] = e
+		events = append(events, ev)
+	}
+	cb := s.callback
+	s.mutex.Unlock()
+	if cb != nil && len(events) > 0 {
+		cb(events)
+	}
+}
+
+// remove 删除path的配置, replaced中的key已经被原来的source代替, 不产生事件
+func (s *snapshotSource) remove(path string, replaced []string) {
+	skip := make(map[string]bool, len(replaced))
+	for _, k := range replaced {
+		skip[k] = true
+	}
+	var events []*Event
+	s.mutex.Lock()
+	for k, e := range s.entries {
+		if e.Path != path {
+			continue
+		}
+		delete(s.entries, k)
+		if !skip[k] {
+			ev := source.NewEvent(Deleted, k)
+			ev.Source, ev.Path, ev.ValueFrom = s.name, path, e.Value
+			events = append(events, ev)
+		}
+	}
+	cb := s.callback
+	s.mutex.Unlock()
+	if cb != nil && len(events) > 0 {
+		cb(events)
+	}
+}
@@ -0,0 +1,106 @@
+package vade
+
+import (
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+
+    "github.com/stretchr/testify/assert"
+
+    "github.com/derry6/vade-go/source"
+    "github.com/derry6/vade-go/source/client"
+)
+
+func newTestRemote(t *testing.T) source.Source {
+    c, err := client.New(client.File, client.DefaultConfig())
+    assert.NoError(t, err)
+    return source.New("remote", c, source.WithPriority(DefaultRemotePriority))
+}
+
+func TestSnapshotBoot(t *testing.T) {
+    dir, err := ioutil.TempDir("", "vade")
+    assert.NoError(t, err)
+    defer os.RemoveAll(dir)
+    file := filepath.Join(dir, "app.yaml")
+    cacheDir := filepath.Join(dir, "cache")
+    assert.NoError(t, ioutil.WriteFile(file, []byte("db:\n  addr: a:3306\n  pool: 10\n"), 0644))
+
+    mgr, err := NewManager(WithSnapshot(cacheDir))
+    assert.NoError(t, err)
+    assert.NoError(t, mgr.AddSource(newTestRemote(t)))
+    assert.NoError(t, mgr.AddPath("remote", file, source.WithPathRequired()))
+    // source的事件是异步派发的
+    var snap *Snapshot
+    for i := 0; i < 100 && snap == nil; i++ {
+        time.Sleep(10 * time.Millisecond)
+        snap, _ = ReadSnapshot(cacheDir)
+    }
+    if !assert.NotNil(t, snap) {
+        return
+    }
+    assert.Contains(t, snap.Entries, SnapshotEntry{Key: "db.pool", Value: 10, Source: "remote", Path: file})
+    if info, err := os.Stat(filepath.Join(cacheDir, SnapshotFile)); assert.NoError(t, err) {
+        assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+    }
+
+    // 远程配置不可用时从快照启动
+    assert.NoError(t, os.Remove(file))
+    interval := snapshotRetryInterval
+    snapshotRetryInterval = 10 * time.Millisecond
+    defer func() { snapshotRetryInterval = interval }()
+    mgr, err = NewManager(WithSnapshot(cacheDir), WithSnapshotBoot())
+    assert.NoError(t, err)
+    assert.NoError(t, mgr.AddSource(newTestRemote(t)))
+    assert.NoError(t, mgr.AddPath("remote", file, source.WithPathRequired()))
+    assert.Equal(t, []string{"remote:" + file}, mgr.Degraded())
+    v, _ := mgr.Get("db.addr")
+    assert.Equal(t, "a:3306", v)
+    name, path, _ := mgr.Origin("db.addr")
+    assert.Equal(t, "snapshot:remote", name)
+    assert.Equal(t, file, path)
+
+    // 恢复后使用最新的配置
+    assert.NoError(t, ioutil.WriteFile(file, []byte("db:\n  addr: b:3306\n"), 0644))
+    for i := 0; i < 100 && len(mgr.Degraded()) > 0; i++ {
+        time.Sleep(10 * time.Millisecond)
+    }
+    assert.Empty(t, mgr.Degraded())
+    v, _ = mgr.Get("db.addr")
+    assert.Equal(t, "b:3306", v)
+    _, ok := mgr.Get("db.pool")
+    assert.False(t, ok)
+}
+
+func TestSnapshotBootInit(t *testing.T) {
+    dir, err := ioutil.TempDir("", "vade")
+    assert.NoError(t, err)
+    defer os.RemoveAll(dir)
+    file := filepath.Join(dir, "app.yaml")
+    cacheDir := filepath.Join(dir, "cache")
+    assert.NoError(t, ioutil.WriteFile(file, []byte("db:\n  addr: a:3306\n"), 0644))
+    _, err = NewManager(WithSnapshot(cacheDir), WithFileSource([]string{file}, nil))
+    assert.NoError(t, err)
+
+    // Init中必须的文件不可用时从快照启动
+    assert.NoError(t, os.Remove(file))
+    _, err = NewManager(WithFileSource([]string{file}, nil))
+    assert.Error(t, err)
+    interval := snapshotRetryInterval
+    snapshotRetryInterval = 10 * time.Millisecond
+    defer func() { snapshotRetryInterval = interval }()
+    mgr, err := NewManager(WithSnapshot(cacheDir), WithSnapshotBoot(), WithFileSource([]string{file}, nil))
+    if !assert.NoError(t, err) {
+        return
+    }
+    assert.Equal(t, []string{"file:" + file}, mgr.Degraded())
+    v, _ := mgr.Get("db.addr")
+    assert.Equal(t, "a:3306", v)
+
+    // 关闭后不再重试
+    assert.NoError(t, mgr.Close())
+    assert.NoError(t, ioutil.WriteFile(file, []byte("db:\n  addr: b:3306\n"), 0644))
+    time.Sleep(100 * time.Millisecond)
+    assert.Equal(t, []string{"file:" + file}, mgr.Degraded())
+}
@@ -19,16 +19,21 @@ var (
 )
 
 type BaseSource struct {
-	name          string
-	withDeleted   bool
-	stores        []*pathStore
-	values        map[string]*configValue // 根据优先级聚合后的配置
-	callback      func([]*Event)
-	defaultParser parser.Parser
-	client        client.Client
-	prefix        string
-	priority      int
-	mutex         sync.RWMutex
+	name           string
+	withDeleted    bool
+	importDisabled bool
+	stores         []*pathStore
+	values         map[string]*configValue // 根据优先级聚合后的配置
+	callback       func([]*Event)
+	defaultParser  parser.Parser
+	client         client.Client
+	prefix         string
+	priority       int
+	normalize      KeyNormalizer
+	originals      map[string]string // 规范化之前的key
+	importer       ImportFunc
+	unimporter     UnimportFunc
+	mutex          sync.RWMutex
 }
 
 func (bs *BaseSource) Close() error          { return bs.client.Close() }
@@ -36,11 +41,77 @@ func (bs *BaseSource) Name() string          { return bs.name }
 func (bs *BaseSource) Client() client.Client { return bs.client }
 func (bs *BaseSource) Priority() int         { return bs.priority }
 
+// parserOf 选择path的parser: 指定的parser, 客户端返回的格式, path的扩展名。
+// 返回nil时使用默认的parser自动检测。
+func (bs *BaseSource) parserOf(path string, p parser.Parser, format string) parser.Parser {
+	if p != nil {
+		return p
+	}
+	if p, ok := parser.Get(format); ok {
+		return p
+	}
+	return parser.ForPath(path)
+}
+
 func (bs *BaseSource) parse(p parser.Parser, data []byte) (v map[string]interface{}, err error) {
 	if p == nil {
-		return bs.defaultParser.Parse(data, bs.prefix)
+		p = bs.defaultParser
+	}
+	if v, err = p.Parse(data, bs.prefix); err != nil || bs.normalize == nil {
+		return v, err
+	}
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+	return bs.normalizeValues(v), nil
+}
+
+func (bs *BaseSource) normalizeKey(key string) string {
+	if bs.normalize == nil {
+		return key
+	}
+	return bs.normalize(key)
+}
+
+// OriginalKey 返回规范化之前的key, 用于解码map的key
+func (bs *BaseSource) OriginalKey(key string) (string, bool) {
+	bs.mutex.RLock()
+	defer bs.mutex.RUnlock()
+	k, ok := bs.originals[key]
+	return k, ok
+}
+
+// normalizeValues 规范化values的key并记录原始的key, 调用者需要持有锁
+func (bs *BaseSource) normalizeValues(values map[string]interface{}) map[string]interface{} {
+	if values == nil {
+		return nil
+	}
+	normalized := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		key := bs.normalizeKey(k)
+		normalized[key] = v
+		if key != k {
+			bs.originals[key] = k
+		}
 	}
-	return p.Parse(data, bs.prefix)
+	return normalized
+}
+
+// SetKeyNormalizer 设置key的规范化函数, 已有的配置也会被规范化
+func (bs *BaseSource) SetKeyNormalizer(fn KeyNormalizer) {
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+	bs.normalize = fn
+	if fn == nil {
+		return
+	}
+	for _, store := range bs.stores {
+		store.values = bs.normalizeValues(store.values)
+	}
+	values := make(map[string]*configValue, len(bs.values))
+	for k, v := range bs.values {
+		values[fn(k)] = v
+	}
+	bs.values = values
 }
 
 // 设置回调
@@ -77,7 +148,7 @@ func (bs *BaseSource) All() (values map[string]interface{}) {
 func (bs *BaseSource) Get(key string) (value interface{}, ok bool) {
 	bs.mutex.RLock()
 	defer bs.mutex.RUnlock()
-	v, ok := bs.values[key]
+	v, ok := bs.values[bs.normalizeKey(key)]
 	if ok {
 		if v == nil {
 			return nil, false
@@ -87,10 +158,22 @@ func (bs *BaseSource) Get(key string) (value interface{}, ok bool) {
 	return nil, false
 }
 
+// PathOf 返回key所在的配置集合
+func (bs *BaseSource) PathOf(key string) (path string, ok bool) {
+	bs.mutex.RLock()
+	defer bs.mutex.RUnlock()
+	v, ok := bs.values[bs.normalizeKey(key)]
+	if !ok || v == nil || v.store == nil {
+		return "", false
+	}
+	return v.store.path, true
+}
+
 // 设置配置
 func (bs *BaseSource) Set(key string, value interface{}) {
 	bs.mutex.Lock()
 	defer bs.mutex.Unlock()
+	key = bs.normalizeKey(key)
 	// 设置到每个namespace当中
 	for _, n := range bs.stores {
 		if _, ok := n.values[key]; ok {
@@ -116,12 +199,51 @@ func (bs *BaseSource) AddPath(path string, opts ...PathOption) error {
 	return bs.addPath(path, pOpts)
 }
 
+// 删除配置, 该path中的配置会产生删除事件
+func (bs *BaseSource) RemovePath(path string) error {
+	bs.mutex.Lock()
+	store := bs.findStore(path)
+	if store == nil {
+		bs.mutex.Unlock()
+		return nil
+	}
+	for i, s := range bs.stores {
+		if s == store {
+			bs.stores = append(bs.stores[:i], bs.stores[i+1:]...)
+			break
+		}
+	}
+	var events []*Event
+	for key, value := range store.values {
+		ev := NewEvent(Deleted, key)
+		ev.Path = path
+		ev.ValueFrom = value
+		if bs.handleDeleted(store, ev) {
+			events = append(events, ev)
+		}
+	}
+	imports := store.imports
+	store.imports = nil
+	bs.mutex.Unlock()
+	if !store.opts.watchDisabled {
+		if err := client.Unwatch(bs.client, path); err != nil {
+			log.Get().Warnf("Can't unwatch path %q of source %q: %v", path, bs.Name(), err)
+		}
+	}
+	bs.dispatchEvents(events)
+	// 同时删除该path导入的配置
+	for _, r := range imports {
+		bs.unimport(r)
+	}
+	return nil
+}
+
 func (bs *BaseSource) watchPath(path string, pOpts *pathOptions) error {
 	if pOpts.watchDisabled {
 		return nil
 	}
-	return bs.client.Watch(path, func(data []byte) {
-		e2 := bs.handlePathUpdated(path, data)
+	return client.WatchResponse(bs.client, path, func(rsp *client.Response) {
+		e2 := bs.handlePathUpdated(path, rsp)
 		if e2 != nil {
 			log.Get().Errorf("Can't handle path config updated, source: %q, path: %q :%v", bs.Name(), path, e2)
 		}
@@ -135,9 +257,10 @@ func (bs *BaseSource) addPath(path string, pOpts *pathOptions) error {
 			pri:    pOpts.priority,
 			parser: pOpts.parser,
 			values: map[string]interface{}{},
+			opts:   pOpts,
 		}
 	)
-	data, err := bs.client.Pull(context.Background(), path)
+	rsp, err := client.PullResponse(context.Background(), bs.client, path)
 	if err != nil {
 		if pOpts.required {
 			return pkgerrs.Wrapf(err, "pull required path configs")
@@ -145,7 +268,8 @@ func (bs *BaseSource) addPath(path string, pOpts *pathOptions) error {
 			log.Get().Warnf("Can not pull path %q configs: %v", path, err)
 		}
 	} else {
-		values, err = bs.parse(pOpts.parser, data)
+		store.format, store.version = rsp.Format, rsp.Version
+		values, err = bs.parse(bs.parserOf(path, store.parser, rsp.Format), rsp.Data)
 		if err != nil {
 			if pOpts.required {
 				return pkgerrs.Wrapf(err, "parse required path configs")
@@ -154,17 +278,52 @@ func (bs *BaseSource) addPath(path string, pOpts *pathOptions) error {
 			}
 		}
 	}
+	refs, err := bs.resolveImports(store, values)
+	if err != nil {
+		if pOpts.required {
+			return err
+		}
+		log.Get().Warnf("Can not import configs of path %q: %v", path, err)
+	}
 	bs.mutex.Lock()
 	bs.stores = append(bs.stores, store)
 	sort.Sort(pathHighToLow(bs.stores))
 	events := bs.populateEvents(store, values)
 	bs.mutex.Unlock()
+	bs.dispatchEvents(events)
+	if err = bs.addImports(store, refs); err != nil {
+		if pOpts.required {
+			// 导入失败时不保留导入者
+			_ = bs.RemovePath(path)
+			return err
+		}
+		log.Get().Warnf("Can not import configs of path %q: %v", path, err)
+	}
 	// todo: handle errors
 	_ = bs.watchPath(path, pOpts)
-	bs.dispatchEvents(events)
 	return nil
 }
 
+// PathVersion 返回path当前配置的版本和格式
+func (bs *BaseSource) PathVersion(path string) (version string, format string, ok bool) {
+	bs.mutex.RLock()
+	defer bs.mutex.RUnlock()
+	if store := bs.findStore(path); store != nil {
+		return store.version, store.format, true
+	}
+	return "", "", false
+}
+
+// Paths 返回已添加的path, 按优先级从高到低
+func (bs *BaseSource) Paths() (paths []string) {
+	bs.mutex.RLock()
+	defer bs.mutex.RUnlock()
+	for _, store := range bs.stores {
+		paths = append(paths, store.path)
+	}
+	return paths
+}
+
 func (bs *BaseSource) findStore(path string) *pathStore {
 	for _, store := range bs.stores {
 		if store.path == path {
@@ -186,22 +345,46 @@ func (bs *BaseSource) findAnotherStore(key string, s1 *pathStore) (v2 interface{
 }
 
 // 处理namespace内容变更事件
-func (bs *BaseSource) handlePathUpdated(path string, data []byte) error {
+func (bs *BaseSource) handlePathUpdated(path string, rsp *client.Response) error {
+	return bs.updatePath(path, rsp, false)
+}
+
+// Reload 重新拉取path的配置, 返回前同步派发事件
+func (bs *BaseSource) Reload(path string) error {
+	rsp, err := client.PullResponse(context.Background(), bs.client, path)
+	if err != nil {
+		return err
+	}
+	return bs.updatePath(path, rsp, true)
+}
+
+func (bs *BaseSource) updatePath(path string, rsp *client.Response, sync bool) error {
 	bs.mutex.RLock()
 	p := bs.findStore(path)
 	bs.mutex.RUnlock()
 	if p == nil {
 		return pkgerrs.New("path not found")
 	}
-	values, err := bs.parse(p.parser, data)
+	values, err := bs.parse(bs.parserOf(path, p.parser, rsp.Format), rsp.Data)
 	if err != nil {
 		return err
 	}
+	refs, rerr := bs.resolveImports(p, values)
 	bs.mutex.Lock()
+	p.format, p.version = rsp.Format, rsp.Version
 	events := bs.populateEvents(p, values)
 	bs.mutex.Unlock()
-	bs.dispatchEvents(events)
-	return nil
+	if sync {
+		if cb := bs.callback; len(events) > 0 && cb != nil {
+			cb(events)
+		}
+	} else {
+		bs.dispatchEvents(events)
+	}
+	if err = bs.addImports(p, refs); err != nil {
+		return err
+	}
+	return rerr
 }
 
 func (bs *BaseSource) handleCreated(store *pathStore, ev *Event) bool {
@@ -340,15 +523,18 @@ func (bs *BaseSource) dispatchEvents(events []*Event) {
 
 func newBaseSource(name string, c client.Client, opts *options) *BaseSource {
 	return &BaseSource{
-		client:        c,
-		name:          name,
-		withDeleted:   opts.withDeleted,
-		prefix:        opts.prefix,
-		priority:      opts.priority,
-		stores:        make([]*pathStore, 0),
-		values:        map[string]*configValue{},
-		callback:      nil,
-		defaultParser: parser.NewDefault(),
-		mutex:         sync.RWMutex{},
+		client:         c,
+		name:           name,
+		withDeleted:    opts.withDeleted,
+		importDisabled: opts.importDisabled,
+		prefix:         opts.prefix,
+		priority:       opts.priority,
+		normalize:      opts.normalize,
+		stores:         make([]*pathStore, 0),
+		values:         map[string]*configValue{},
+		originals:      map[string]string{},
+		callback:       nil,
+		defaultParser:  parser.NewDefault(),
+		mutex:          sync.RWMutex{},
 	}
 }
@@ -6,6 +6,8 @@ import (
     "os"
     "path"
     "path/filepath"
+    "strconv"
+    "strings"
     "sync"
 
     pkgerrs "github.com/pkg/errors"
@@ -37,12 +39,17 @@ func (c *Snapshot) getReleaseKey(p *configPath) (key string, nId int64) {
     }
     c.mutex.RLock()
     data, err := ioutil.ReadFile(fmt.Sprintf("%s.release", c.file(p)))
-    c.mutex.Unlock()
+    c.mutex.RUnlock()
     if err != nil {
         return
     }
-    _, _ = fmt.Sscanf(string(data), "%s:%d", &key, &nId)
-    return
+    // releaseKey:notificationId
+    s := string(data)
+    if i := strings.LastIndex(s, ":"); i >= 0 {
+        nId, _ = strconv.ParseInt(s[i+1:], 10, 64)
+        s = s[:i]
+    }
+    return s, nId
 }
 
 func (c *Snapshot) save(p *configPath, release string, nId int64, data []byte) error {
@@ -81,7 +88,7 @@ func (c *Snapshot) delete(p *configPath) error {
     return err
 }
 
-func (c Snapshot) clean() (err error) {
+func (c *Snapshot) clean() (err error) {
     if c.disable {
         return pkgerrs.New("snapshot disabled")
     }
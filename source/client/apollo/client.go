@@ -31,7 +31,10 @@ var (
 )
 
 var (
-    _ client.Client = (*Client)(nil)
+    _ client.Client          = (*Client)(nil)
+    _ client.Formatter       = (*Client)(nil)
+    _ client.ResponsePuller  = (*Client)(nil)
+    _ client.ResponseWatcher = (*Client)(nil)
 )
 
 func init() {
@@ -48,10 +51,10 @@ type Client struct {
     selector      Selector
     snapshot      *Snapshot
     mutex         sync.RWMutex
-    nIDs          map[string]int64                  // namespace@cluster@app -> id
-    releases      map[string]string                 // namespace@cluster@app -> id
-    callbacks     map[string]client.ChangedCallback // namespace@cluster@app -> callbacks
-    watches       map[string][]string               // cluster@app -> namespaces
+    nIDs          map[string]int64                   // namespace@cluster@app -> id
+    releases      map[string]string                  // namespace@cluster@app -> id
+    callbacks     map[string]client.ResponseCallback // namespace@cluster@app -> callbacks
+    watches       map[string][]string                // cluster@app -> namespaces
     watchDisabled bool
 }
 
@@ -75,6 +78,15 @@ func (c *Client) lastReleaseOf(fullKey string) (release string) {
 }
 
 func (c *Client) Pull(ctx context.Context, path string) ([]byte, error) {
+    rsp, err := c.PullResponse(ctx, path)
+    if err != nil {
+        return nil, err
+    }
+    return rsp.Data, nil
+}
+
+// PullResponse 版本为namespace的releaseKey
+func (c *Client) PullResponse(ctx context.Context, path string) (*client.Response, error) {
     var (
         release string
         data    []byte
@@ -92,10 +104,14 @@ func (c *Client) Pull(ctx context.Context, path string) ([]byte, error) {
         }
         release, nId = c.snapshot.getReleaseKey(p)
         c.setRelease(fullKey, release, nId)
-        return data, nil
+        return &client.Response{Data: data, Format: p.format(), Version: release}, nil
     }
     c.setRelease(fullKey, release, 0)
-    return data, err
+    return &client.Response{Data: data, Format: p.format(), Version: release}, nil
+}
+
+func (c *Client) Format(path string) string {
+    return buildConfigPath(path, c.cluster, c.appId, c.token).format()
 }
 
 func (c *Client) Push(ctx context.Context, path string, data []byte) error {
@@ -103,6 +119,10 @@ func (c *Client) Push(ctx context.Context, path string, data []byte) error {
 }
 
 func (c *Client) Watch(path string, cb client.ChangedCallback) error {
+    return c.WatchResponse(path, func(rsp *client.Response) { cb(rsp.Data) })
+}
+
+func (c *Client) WatchResponse(path string, cb client.ResponseCallback) error {
     p := buildConfigPath(path, c.cluster, c.appId, c.token)
     c.mutex.Lock()
     defer c.mutex.Unlock()
@@ -192,7 +212,7 @@ func (c *Client) onConfigChanged(p *configPath, nId int64) {
     cb, _ := c.callbacks[fullKey]
     c.mutex.RUnlock()
     if cb != nil {
-        cb(content)
+        cb(&client.Response{Data: content, Format: p.format(), Version: newKey})
     }
 }
 
@@ -232,7 +252,7 @@ func NewClient(cfg *client.Config) (client.Client, error) {
         releases:      make(map[string]string),
         cli:           newHttpClient(),
         watches:       map[string][]string{},
-        callbacks:     map[string]client.ChangedCallback{},
+        callbacks:     map[string]client.ResponseCallback{},
         watchDisabled: cfg.WatchDisabled,
     }, nil
 }
@@ -20,6 +20,15 @@ func (p *configPath) extension() string {
     return "properties"
 }
 
+// format yaml和json以外的namespace, 返回的是json格式的configurations
+func (p *configPath) format() string {
+    switch ext := p.extension(); ext {
+    case "yaml", "yml", "json":
+        return ext
+    }
+    return "json"
+}
+
 func (p *configPath) fullKey() string {
     return p.namespace + "@" + p.appId + "@" + p.cluster
 }
@@ -21,6 +21,24 @@ type Client interface {
     Watch(path string, cb ChangedCallback) error
 }
 
+// Unwatcher 停止监听path, source删除path时调用
+type Unwatcher interface {
+    Unwatch(path string) error
+}
+
+// Unwatch 停止监听path, 客户端没有实现Unwatcher时忽略
+func Unwatch(c Client, path string) error {
+    if u, ok := c.(Unwatcher); ok {
+        return u.Unwatch(path)
+    }
+    return nil
+}
+
+// Formatter 返回path中配置的格式(扩展名), 用于选择parser
+type Formatter interface {
+    Format(path string) string
+}
+
 type Constructor func(config *Config) (Client, error)
 
 func RegisterClient(name string, constructor Constructor) error {
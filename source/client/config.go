@@ -9,13 +9,20 @@ import (
     "time"
 
     "github.com/hashicorp/go-rootcerts"
+    "github.com/spf13/pflag"
+
+    "github.com/derry6/vade-go/pkg/envkey"
 )
 
 // Config client configurations
 type Config struct {
     // env
-    Flags   map[string]interface{} `json:"-"`
-    FlagSet *flag.FlagSet          `json:"-"`
+    EnvMapper *envkey.Mapper `json:"-"` // 环境变量名的映射规则, 为空时使用默认规则
+    // flag
+    Flags    map[string]interface{} `json:"-"` // 已废弃, 读取FlagSet中所有设置的参数
+    FlagSet  *flag.FlagSet          `json:"-"`
+    PFlagSet *pflag.FlagSet         `json:"-"` // pflag/cobra的参数, 设置后忽略FlagSet
+    Args     []string               `json:"-"` // 参数还没有解析时使用的命令行, 默认为os.Args[1:]
     // common
     Timeout       time.Duration `json:"timeout,omitempty" yaml:"timeout"`
     WatchTimeout  time.Duration `json:"watchTimeout,omitempty" yaml:"watchTimeout"`
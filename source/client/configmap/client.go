@@ -0,0 +1,268 @@
+package configmap
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	pkgerrs "github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/derry6/vade-go/pkg/log"
+	"github.com/derry6/vade-go/source/client"
+	"github.com/derry6/vade-go/source/parser"
+)
+
+const (
+	Name       = "configmap"
+	SecretName = "secret"
+)
+
+const (
+	// kubernetes通过原子地替换..data符号链接来更新挂载的文件
+	dataDir       = "..data"
+	reloadLatency = 100 * time.Millisecond
+)
+
+var (
+	_ client.Client    = (*Client)(nil)
+	_ client.Formatter = (*Client)(nil)
+	_ client.Unwatcher = (*Client)(nil)
+)
+
+func init() {
+	_ = client.RegisterClient(Name, NewClient)
+	_ = client.RegisterClient(SecretName, NewClient)
+}
+
+type watch struct {
+	path  string
+	dir   string
+	cb    client.ChangedCallback
+	md5   string
+	timer *time.Timer
+}
+
+// Client 读取挂载到目录的ConfigMap/Secret.
+// 目录中每个文件是一个key, 扩展名为yaml/yml/json/properties的文件作为完整的配置文档。
+type Client struct {
+	w       *fsnotify.Watcher
+	mutex   sync.RWMutex
+	watches map[string]*watch
+	closed  bool
+}
+
+func (c *Client) Close() error {
+	// 停止等待中的重新加载
+	c.mutex.Lock()
+	c.closed = true
+	for _, w := range c.watches {
+		if w.timer != nil {
+			w.timer.Stop()
+			w.timer = nil
+		}
+	}
+	c.mutex.Unlock()
+	if c.w != nil {
+		return c.w.Close()
+	}
+	return nil
+}
+
+func (c *Client) Pull(ctx context.Context, path string) (data []byte, err error) {
+	data, err = read(path)
+	if err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	if w, ok := c.watches[path]; ok {
+		w.md5 = md5sum(data)
+	}
+	c.mutex.Unlock()
+	return data, nil
+}
+
+// Format 目录中的配置以yaml格式输出, 单个文件使用文件的扩展名
+func (c *Client) Format(path string) string {
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		return strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+	return "yaml"
+}
+
+func (c *Client) Push(ctx context.Context, path string, data []byte) error {
+	return pkgerrs.New("configmap is read only")
+}
+
+func (c *Client) Watch(path string, cb client.ChangedCallback) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.w == nil {
+		return pkgerrs.New("watch disabled")
+	}
+	if _, ok := c.watches[path]; ok {
+		return nil
+	}
+	dir := path
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		dir = filepath.Dir(path)
+	}
+	dir = filepath.Clean(dir)
+	w := &watch{path: path, dir: dir, cb: cb}
+	if data, err := read(path); err == nil {
+		w.md5 = md5sum(data)
+	}
+	c.watches[path] = w
+	return c.w.Add(dir)
+}
+
+// Unwatch 停止监听path, 目录没有其他path监听时不再监听目录
+func (c *Client) Unwatch(path string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	w, ok := c.watches[path]
+	if !ok {
+		return nil
+	}
+	delete(c.watches, path)
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	for _, other := range c.watches {
+		if other.dir == w.dir {
+			return nil
+		}
+	}
+	_ = c.w.Remove(w.dir)
+	return nil
+}
+
+func md5sum(data []byte) string {
+	m5 := md5.New()
+	m5.Write(data)
+	return hex.EncodeToString(m5.Sum(nil))
+}
+
+// read 读取目录中的所有配置, 通过..data读取, 确保读到的是同一个版本
+func read(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return ioutil.ReadFile(path)
+	}
+	root := path
+	if target, err := filepath.EvalSymlinks(filepath.Join(path, dataDir)); err == nil {
+		root = target
+	}
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]interface{}{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		file := filepath.Join(root, name)
+		if fi, err := os.Stat(file); err != nil || fi.IsDir() {
+			continue
+		}
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		p := parser.ForPath(name)
+		if p == nil {
+			values[name] = strings.TrimSuffix(string(content), "\n")
+			continue
+		}
+		doc, err := p.Parse(content, "")
+		if err != nil {
+			return nil, pkgerrs.Wrapf(err, "parse %q", name)
+		}
+		for k, v := range doc {
+			values[k] = v
+		}
+	}
+	return yaml.Marshal(values)
+}
+
+// 一次..data替换会产生多个事件, 延迟后只重新加载一次
+func (c *Client) handleEvent(event fsnotify.Event) {
+	dir := filepath.Dir(event.Name)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return
+	}
+	for _, w := range c.watches {
+		if w.dir != dir {
+			continue
+		}
+		if w.timer != nil {
+			w.timer.Stop()
+		}
+		w := w
+		w.timer = time.AfterFunc(reloadLatency, func() { c.reload(w) })
+	}
+}
+
+func (c *Client) reload(w *watch) {
+	data, err := read(w.path)
+	if err != nil {
+		log.Get().Warnf("Can't read configmap %q: %v", w.path, err)
+		return
+	}
+	sum := md5sum(data)
+	c.mutex.Lock()
+	if c.closed || c.watches[w.path] != w {
+		c.mutex.Unlock()
+		return
+	}
+	changed := sum != w.md5
+	w.md5 = sum
+	c.mutex.Unlock()
+	if changed && w.cb != nil {
+		w.cb(data)
+	}
+}
+
+func (c *Client) start() {
+	for {
+		select {
+		case event, ok := <-c.w.Events:
+			if !ok {
+				return
+			}
+			c.handleEvent(event)
+		case err, ok := <-c.w.Errors:
+			if !ok {
+				return
+			}
+			log.Get().Errorf("Configmap watcher error: %v", err)
+		}
+	}
+}
+
+func NewClient(cfg *client.Config) (client.Client, error) {
+	c := &Client{watches: map[string]*watch{}}
+	if !cfg.WatchDisabled {
+		w, err := fsnotify.NewWatcher()
+		if err != nil {
+			return nil, err
+		}
+		c.w = w
+		go c.start()
+	}
+	return c, nil
+}
@@ -0,0 +1,107 @@
+package configmap
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/derry6/vade-go/source/client"
+	"github.com/derry6/vade-go/source/parser"
+)
+
+// 模拟kubernetes更新挂载的ConfigMap
+func swapData(t *testing.T, dir, version string, files map[string]string) {
+	tsDir := filepath.Join(dir, "..ts_"+version)
+	assert.NoError(t, os.Mkdir(tsDir, 0755))
+	for name, content := range files {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(tsDir, name), []byte(content), 0644))
+		link := filepath.Join(dir, name)
+		if _, err := os.Lstat(link); err != nil {
+			assert.NoError(t, os.Symlink(filepath.Join(dataDir, name), link))
+		}
+	}
+	tmp := filepath.Join(dir, "..data_tmp")
+	assert.NoError(t, os.Symlink(filepath.Base(tsDir), tmp))
+	assert.NoError(t, os.Rename(tmp, filepath.Join(dir, dataDir)))
+}
+
+func parse(t *testing.T, data []byte) map[string]interface{} {
+	values, err := parser.NewYAML().Parse(data, "")
+	assert.NoError(t, err)
+	return values
+}
+
+func TestClient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vade-configmap")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	swapData(t, dir, "1", map[string]string{
+		"log.level": "debug\n",
+		"app.yaml":  "app:\n  name: v1\n",
+	})
+	c, err := NewClient(client.DefaultConfig())
+	assert.NoError(t, err)
+	defer c.Close()
+
+	data, err := c.Pull(context.TODO(), dir)
+	assert.NoError(t, err)
+	values := parse(t, data)
+	assert.Equal(t, "debug", values["log.level"])
+	assert.Equal(t, "v1", values["app.name"])
+
+	reloads := make(chan []byte, 10)
+	assert.NoError(t, c.Watch(dir, func(data []byte) { reloads <- data }))
+	swapData(t, dir, "2", map[string]string{
+		"log.level": "info",
+		"app.yaml":  "app:\n  name: v2\n",
+	})
+	select {
+	case data = <-reloads:
+		values = parse(t, data)
+		assert.Equal(t, "info", values["log.level"])
+		assert.Equal(t, "v2", values["app.name"])
+	case <-time.After(3 * time.Second):
+		t.Fatal("wait reload timeout")
+	}
+	select {
+	case <-reloads:
+		t.Fatal("should reload only once per swap")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	// Close后不再重新加载
+	swapData(t, dir, "3", map[string]string{"log.level": "warn"})
+	assert.NoError(t, c.Close())
+	select {
+	case <-reloads:
+		t.Fatal("should not reload after close")
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestUnwatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vade-configmap")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	swapData(t, dir, "1", map[string]string{"log.level": "debug"})
+	c, err := NewClient(client.DefaultConfig())
+	assert.NoError(t, err)
+	defer c.Close()
+
+	reloads := make(chan []byte, 10)
+	assert.NoError(t, c.Watch(dir, func(data []byte) { reloads <- data }))
+	assert.NoError(t, client.Unwatch(c, dir))
+	swapData(t, dir, "2", map[string]string{"log.level": "info"})
+	select {
+	case <-reloads:
+		t.Fatal("should not reload after unwatch")
+	case <-time.After(300 * time.Millisecond):
+	}
+}
@@ -5,6 +5,8 @@ import (
     "crypto/tls"
     "errors"
     "net/http"
+    pathpkg "path"
+    "strconv"
     "strings"
     "sync"
     "time"
@@ -19,44 +21,118 @@ const (
     Name = "consul"
 )
 
+// KVPair.Flags中记录的配置格式
+const (
+    FlagNone uint64 = iota
+    FlagJSON
+    FlagYAML
+    FlagProperties
+    FlagTOML
+    FlagINI
+    FlagHCL
+)
+
 var (
-    _ client.Client = (*Client)(nil)
+    _ client.Client          = (*Client)(nil)
+    _ client.Formatter       = (*Client)(nil)
+    _ client.ResponsePuller  = (*Client)(nil)
+    _ client.ResponseWatcher = (*Client)(nil)
+    _ client.Unwatcher       = (*Client)(nil)
 )
 
+var formatFlags = map[uint64]string{
+    FlagJSON:       "json",
+    FlagYAML:       "yaml",
+    FlagProperties: "properties",
+    FlagTOML:       "toml",
+    FlagINI:        "ini",
+    FlagHCL:        "hcl",
+}
+
+// FormatFlag 返回格式对应的flag, 未知的格式返回FlagNone
+func FormatFlag(format string) uint64 {
+    if format == "yml" {
+        format = "yaml"
+    }
+    for flag, f := range formatFlags {
+        if f == format {
+            return flag
+        }
+    }
+    return FlagNone
+}
+
 type Client struct {
     client        *consulapi.KV
     watchDisabled bool // watch enable
     dataCenter    string
     timeout       time.Duration
     mutex         sync.RWMutex
-    watchers      map[string]func(data []byte)
+    watchers      map[string]*watcher
+}
+
+type watcher struct {
+    cb client.ResponseCallback
 }
 
 func (c *Client) Close() error { return nil }
 func (c *Client) Name() string { return Name }
 
 func (c *Client) Pull(ctx context.Context, path string) (data []byte, err error) {
+    rsp, err := c.PullResponse(ctx, path)
+    if err != nil {
+        return nil, err
+    }
+    return rsp.Data, nil
+}
+
+// PullResponse 格式优先使用KVPair.Flags, 版本为ModifyIndex
+func (c *Client) PullResponse(ctx context.Context, path string) (*client.Response, error) {
     opts := &consulapi.QueryOptions{Datacenter: c.dataCenter}
-    kv, _, err := c.client.Get(path, opts)
+    kv, _, err := c.client.Get(path, opts.WithContext(ctx))
     if err != nil {
         return nil, err
     }
     if kv == nil {
         return nil, errors.New("not found")
     }
-    return kv.Value, nil
+    return c.response(kv), nil
+}
+
+func (c *Client) response(kv *consulapi.KVPair) *client.Response {
+    format, ok := formatFlags[kv.Flags]
+    if !ok {
+        format = c.Format(kv.Key)
+    }
+    return &client.Response{
+        Data:    kv.Value,
+        Format:  format,
+        Version: strconv.FormatUint(kv.ModifyIndex, 10),
+    }
+}
+
+// Format 使用key的扩展名作为配置的格式
+func (c *Client) Format(path string) string {
+    return strings.TrimPrefix(pathpkg.Ext(path), ".")
 }
 
 func (c *Client) Push(ctx context.Context, path string, data []byte) error {
     opts := &consulapi.WriteOptions{
         Datacenter: c.dataCenter,
     }
-    kv := &consulapi.KVPair{Key: path, Value: data}
+    kv := &consulapi.KVPair{Key: path, Value: data, Flags: FormatFlag(c.Format(path))}
     _, err := c.client.Put(kv, opts)
     return err
 }
 
 func (c *Client) Watch(path string, cb client.ChangedCallback) error {
+    if cb == nil {
+        return c.WatchResponse(path, nil)
+    }
+    return c.WatchResponse(path, func(rsp *client.Response) { cb(rsp.Data) })
+}
+
+func (c *Client) WatchResponse(path string, cb client.ResponseCallback) error {
     c.mutex.Lock()
     defer c.mutex.Unlock()
     if c.watchDisabled {
@@ -69,16 +145,31 @@ func (c *Client) Watch(path string, cb client.ChangedCallback) error {
         }
         return nil
     }
-    c.watchers[path] = cb
-    go c.doListen(path)
+    w := &watcher{cb: cb}
+    c.watchers[path] = w
+    go c.doListen(path, w)
     return nil
 }
 
-func (c *Client) doListen(path string) {
+// Unwatch 停止监听path, 等待中的查询返回后退出
+func (c *Client) Unwatch(path string) error {
+    c.mutex.Lock()
+    delete(c.watchers, path)
+    c.mutex.Unlock()
+    return nil
+}
+
+func (c *Client) doListen(path string, w *watcher) {
     index := uint64(0)
     waitTime := 10 * c.timeout
-    // todo: close watcher
     for {
+        c.mutex.RLock()
+        current := c.watchers[path]
+        c.mutex.RUnlock()
+        // 已经取消监听或者重新监听
+        if current != w {
+            return
+        }
         opts := consulapi.QueryOptions{WaitIndex: index, WaitTime: waitTime}
         kvp, meta, err := c.client.Get(path, &opts)
         if kvp == nil && err == nil {
@@ -91,11 +182,10 @@ func (c *Client) doListen(path string) {
         }
         index = meta.LastIndex
         c.mutex.RLock()
-        cb, _ := c.watchers[path]
+        current = c.watchers[path]
         c.mutex.RUnlock()
-        if cb != nil {
-            cb(kvp.Value)
-            continue
+        if current == w && w.cb != nil {
+            w.cb(c.response(kvp))
         }
     }
 }
@@ -137,7 +227,7 @@ func NewClient(cfg *client.Config) (client.Client, error) {
         dataCenter:    cfg.DataCenter,
         timeout:       cfg.Timeout,
         mutex:         sync.RWMutex{},
-        watchers:      map[string]func(data []byte){},
+        watchers:      map[string]*watcher{},
     }
     return cli, nil
 }
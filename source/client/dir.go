@@ -0,0 +1,144 @@
+package client
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+
+    "github.com/derry6/vade-go/pkg/log"
+)
+
+// DefaultMaxFileSize 默认的配置文件大小限制
+const DefaultMaxFileSize = 1 * 1024 * 1024
+
+// DirOptions 目录模式的选项
+type DirOptions struct {
+    Pattern     string // 文件名匹配的glob模式, 为空时匹配所有文件
+    Recursive   bool   // 是否包含子目录
+    MaxFileSize int64  // 文件大小限制, 小于等于0时使用DefaultMaxFileSize
+}
+
+func (o *DirOptions) maxFileSize() int64 {
+    if o.MaxFileSize <= 0 {
+        return DefaultMaxFileSize
+    }
+    return o.MaxFileSize
+}
+
+func (o *DirOptions) match(name string) bool {
+    if o.Pattern == "" {
+        return true
+    }
+    ok, _ := filepath.Match(o.Pattern, filepath.Base(name))
+    return ok
+}
+
+// FileTooLargeError 文件超过大小限制
+type FileTooLargeError struct {
+    Path  string
+    Size  int64
+    Limit int64
+}
+
+func (e *FileTooLargeError) Error() string {
+    return fmt.Sprintf("file %q is too large: %d bytes, limit %d bytes", e.Path, e.Size, e.Limit)
+}
+
+// DirChangedCallback 目录中有文件添加或者删除
+type DirChangedCallback func(added []string, removed []string)
+
+// DirWatcher 支持监听目录的客户端
+type DirWatcher interface {
+    WatchDir(dir string, opts *DirOptions, cb DirChangedCallback) error
+}
+
+// 隐藏文件(如编辑器的临时文件)会被忽略
+func isHidden(name string) bool {
+    return strings.HasPrefix(filepath.Base(name), ".")
+}
+
+func checkFileSize(path string, info os.FileInfo, opts *DirOptions) error {
+    if limit := opts.maxFileSize(); info.Size() > limit {
+        return &FileTooLargeError{Path: path, Size: info.Size(), Limit: limit}
+    }
+    return nil
+}
+
+// ListDir 列出root中匹配的文件, root也可以是单个文件。
+// 超过大小限制的文件不会被返回, 并返回第一个FileTooLargeError, 隐藏文件会被忽略。
+// opts为nil时和文件模式一样, 包含隐藏文件, 超过大小限制的文件只告警跳过。
+func ListDir(root string, opts *DirOptions) (files []string, err error) {
+    var tooLarge error
+    plain := opts == nil
+    if plain {
+        opts = &DirOptions{}
+    }
+    err = filepath.Walk(root, func(path string, info os.FileInfo, iErr error) error {
+        if iErr != nil {
+            return iErr
+        }
+        if path == root {
+            if info.IsDir() {
+                return nil
+            }
+        } else {
+            if !plain && isHidden(path) {
+                if info.IsDir() {
+                    return filepath.SkipDir
+                }
+                return nil
+            }
+            if info.IsDir() {
+                if opts.Recursive {
+                    return nil
+                }
+                return filepath.SkipDir
+            }
+            if !opts.match(path) {
+                return nil
+            }
+        }
+        if e := checkFileSize(path, info, opts); e != nil {
+            log.Get().Warnf("Skip config file: %v", e)
+            if tooLarge == nil && !plain {
+                tooLarge = e
+            }
+            return nil
+        }
+        files = append(files, path)
+        return nil
+    })
+    if err == nil {
+        err = tooLarge
+    }
+    return files, err
+}
+
+type dirWatch struct {
+    root  string
+    opts  *DirOptions
+    cb    DirChangedCallback
+    files map[string]bool
+}
+
+// contains 文件是否在监听的目录中
+func (dw *dirWatch) contains(name string) bool {
+    parent := filepath.Dir(name)
+    if parent == dw.root {
+        return true
+    }
+    return dw.opts.Recursive && strings.HasPrefix(parent, dw.root+string(filepath.Separator))
+}
+
+// 删除文件或者子目录中的文件
+func (dw *dirWatch) remove(name string) (removed []string) {
+    prefix := name + string(filepath.Separator)
+    for f := range dw.files {
+        if f == name || strings.HasPrefix(f, prefix) {
+            delete(dw.files, f)
+            removed = append(removed, f)
+        }
+    }
+    return removed
+}
@@ -2,48 +2,92 @@ package client
 
 import (
     "context"
+    "io/ioutil"
     "os"
     "strings"
+    "sync"
 
     "gopkg.in/yaml.v2"
+
+    "github.com/derry6/vade-go/pkg/envkey"
 )
 
 const (
     Env = "env"
+    // 环境变量的path, 其他path作为dotenv文件读取
+    envDefaultPath = "default"
 )
 
 var (
-    _ Client = (*envClient)(nil)
+    _ Client    = (*envClient)(nil)
+    _ Formatter = (*envClient)(nil)
+    _ EnvBinder = (*envClient)(nil)
 )
 
+// EnvBinder 绑定配置的key和环境变量名
+type EnvBinder interface {
+    // EnvName 根据映射规则返回key对应的环境变量名
+    EnvName(key string) string
+    // BindEnv 绑定key和环境变量名, 变量存在时作为key的值, 重新拉取后生效
+    BindEnv(bindings map[string]string)
+}
+
 func init() {
     _ = RegisterClient(Env, newEnvClient)
 }
 
-type envClient struct{}
+type envClient struct {
+    mapper   *envkey.Mapper
+    mu       sync.RWMutex
+    bindings map[string]string // key -> name
+}
 
 func (c *envClient) Close() error { return nil }
 func (c *envClient) Pull(ctx context.Context, path string) (data []byte, err error) {
+    if path != "" && path != envDefaultPath {
+        return ioutil.ReadFile(path)
+    }
     ps := map[string]interface{}{}
     environ := os.Environ()
     for _, item := range environ {
-        fullExpr := []rune(item)
         idx := strings.Index(item, "=")
-        envKey := string(fullExpr[0:idx])
-        propsKey := strings.Replace(envKey, "_", ".", -1)
-        propsKey = strings.ToLower(propsKey)
-        // 保存原始配置和变换后的配置
-        ps[envKey] = string(fullExpr[idx+1:])
-        if propsKey[0] != '.' {
-            ps[propsKey] = ps[envKey]
+        // 根据映射规则保存原始配置和变换后的配置
+        for _, key := range c.mapper.KeysOf(item[0:idx]) {
+            ps[key] = item[idx+1:]
+        }
+    }
+    c.mu.RLock()
+    for key, name := range c.bindings {
+        if v, ok := os.LookupEnv(name); ok {
+            ps[key] = v
         }
     }
+    c.mu.RUnlock()
     data, err = yaml.Marshal(ps)
     return
 }
+// Format 环境变量以yaml格式输出, 其他path为dotenv文件
+func (c *envClient) Format(path string) string {
+    if path != "" && path != envDefaultPath {
+        return "env"
+    }
+    return "yaml"
+}
 func (c *envClient) Push(ctx context.Context, path string, data []byte) error { return nil }
-func (c *envClient) Watch(path string, cb ChangedCallback) error              { return nil }
+func (c *envClient) Watch(path string, cb ChangedCallback) error { return nil }
+
+func (c *envClient) EnvName(key string) string {
+    return c.mapper.Name(key)
+}
+
+func (c *envClient) BindEnv(bindings map[string]string) {
+    c.mu.Lock()
+    for key, name := range bindings {
+        c.bindings[key] = name
+    }
+    c.mu.Unlock()
+}
 
 func newEnvClient(cfg *Config) (Client, error) {
-    return &envClient{}, nil
+    return &envClient{mapper: cfg.EnvMapper, bindings: map[string]string{}}, nil
 }
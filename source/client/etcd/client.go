@@ -2,6 +2,8 @@ package etcd
 
 import (
     "context"
+    pathpkg "path"
+    "strconv"
     "strings"
     "sync"
     "time"
@@ -18,7 +20,11 @@ const (
 )
 
 var (
-    _ client.Client = (*Client)(nil)
+    _ client.Client          = (*Client)(nil)
+    _ client.Formatter       = (*Client)(nil)
+    _ client.ResponsePuller  = (*Client)(nil)
+    _ client.ResponseWatcher = (*Client)(nil)
+    _ client.Unwatcher       = (*Client)(nil)
 )
 
 func init() {
@@ -30,7 +36,7 @@ type Client struct {
     etcd    *etcdv3.Client
     revs    map[string]int64
     watcher etcdv3.Watcher
-    watches map[string]struct{}
+    watches map[string]context.CancelFunc
     close   chan struct{}
     mu      sync.RWMutex
 }
@@ -51,6 +57,15 @@ func (c *Client) Close() error {
 }
 
 func (c *Client) Pull(ctx context.Context, path string) ([]byte, error) {
+    rsp, err := c.PullResponse(ctx, path)
+    if err != nil {
+        return nil, err
+    }
+    return rsp.Data, nil
+}
+
+// PullResponse 格式为key的扩展名, 版本为ModRevision
+func (c *Client) PullResponse(ctx context.Context, path string) (*client.Response, error) {
     var cancel context.CancelFunc
     ctx, cancel = setTimeout(ctx, c.timeout)
     defer cancel()
@@ -64,7 +79,20 @@ func (c *Client) Pull(ctx context.Context, path string) ([]byte, error) {
     c.mu.Lock()
     c.revs[path] = rsp.Header.Revision
     c.mu.Unlock()
-    return rsp.Kvs[0].Value, nil
+    return c.response(rsp.Kvs[0]), nil
+}
+
+func (c *Client) response(kv *mvccpb.KeyValue) *client.Response {
+    return &client.Response{
+        Data:    kv.Value,
+        Format:  c.Format(string(kv.Key)),
+        Version: strconv.FormatInt(kv.ModRevision, 10),
+    }
+}
+
+// Format 使用key的扩展名作为配置的格式
+func (c *Client) Format(path string) string {
+    return strings.TrimPrefix(pathpkg.Ext(path), ".")
 }
 
 func (c *Client) Push(ctx context.Context, path string, data []byte) error {
@@ -78,24 +106,33 @@ func (c *Client) Push(ctx context.Context, path string, data []byte) error {
     return nil
 }
 func (c *Client) Watch(path string, cb client.ChangedCallback) error {
+    return c.WatchResponse(path, func(rsp *client.Response) { cb(rsp.Data) })
+}
+
+func (c *Client) WatchResponse(path string, cb client.ResponseCallback) error {
     c.mu.Lock()
     if _, ok := c.watches[path]; ok {
         c.mu.Unlock()
         return nil
     }
-    c.watches[path] = struct{}{}
+    ctx, cancel := context.WithCancel(context.Background())
+    c.watches[path] = cancel
     startRev, _ := c.revs[path]
     c.mu.Unlock()
-    wc := c.watcher.Watch(context.Background(), path, etcdv3.WithRev(startRev))
+    wc := c.watcher.Watch(ctx, path, etcdv3.WithRev(startRev))
     go func() {
         for {
             select {
-            case rsp := <-wc:
+            case rsp, ok := <-wc:
+                // Unwatch取消后关闭
+                if !ok {
+                    return
+                }
                 for _, ev := range rsp.Events {
                     switch ev.Type {
                     case mvccpb.DELETE:
                     case mvccpb.PUT:
-                        cb(ev.Kv.Value)
+                        cb(c.response(ev.Kv))
                     }
                 }
             case <-c.close:
@@ -106,6 +143,17 @@ func (c *Client) Watch(path string, cb client.ChangedCallback) error {
     return nil
 }
 
+// Unwatch 取消path的监听
+func (c *Client) Unwatch(path string) error {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if cancel, ok := c.watches[path]; ok {
+        cancel()
+        delete(c.watches, path)
+    }
+    return nil
+}
+
 func NewClient(cfg *client.Config) (client.Client, error) {
     if cfg.Address == "" {
         return nil, pkgerrs.New("missing etcd server info")
@@ -132,7 +180,7 @@ func NewClient(cfg *client.Config) (client.Client, error) {
         close:   make(chan struct{}),
         revs:    map[string]int64{},
         watcher: etcdv3.NewWatcher(ec),
-        watches: map[string]struct{}{},
+        watches: map[string]context.CancelFunc{},
         mu:      sync.RWMutex{},
     }, nil
 }
@@ -7,6 +7,8 @@ import (
     "io"
     "io/ioutil"
     "os"
+    "path/filepath"
+    "strings"
     "sync"
     "time"
 
@@ -19,8 +21,12 @@ import (
 const File = "file"
 
 var (
-    _ Client    = (*fileClient)(nil)
-    _ fsWatcher = (*fsnotify.Watcher)(nil)
+    _ Client         = (*fileClient)(nil)
+    _ DirWatcher     = (*fileClient)(nil)
+    _ Formatter      = (*fileClient)(nil)
+    _ ResponsePuller = (*fileClient)(nil)
+    _ Unwatcher      = (*fileClient)(nil)
+    _ fsWatcher      = (*fsnotify.Watcher)(nil)
 )
 
 func init() {
@@ -65,6 +71,7 @@ type fileClient struct {
     md5s map[string]string
     mu   sync.RWMutex
     cbs  map[string]func(data []byte)
+    dirs map[string]*dirWatch
 }
 
 func (c *fileClient) Close() error { return c.stop() }
@@ -79,6 +86,23 @@ func (c *fileClient) Pull(ctx context.Context, path string) (data []byte, err er
     c.md5s[path] = hex.EncodeToString(m5.Sum(nil))
     return
 }
+
+// PullResponse 读取文件, 格式为文件的扩展名, 版本为文件内容的md5
+func (c *fileClient) PullResponse(ctx context.Context, path string) (*Response, error) {
+    data, err := c.Pull(ctx, path)
+    if err != nil {
+        return nil, err
+    }
+    c.mu.RLock()
+    version := c.md5s[path]
+    c.mu.RUnlock()
+    return &Response{Data: data, Format: c.Format(path), Version: version}, nil
+}
+
+func (c *fileClient) Format(path string) string {
+    return strings.TrimPrefix(filepath.Ext(path), ".")
+}
+
 func (c *fileClient) Push(ctx context.Context, path string, data []byte) error {
     return nil
 }
@@ -97,21 +121,168 @@ func (c *fileClient) Watch(path string, cb ChangedCallback) error {
     return c.w.Add(path)
 }
 
+// Unwatch 停止监听文件
+func (c *fileClient) Unwatch(path string) error {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if _, ok := c.cbs[path]; !ok {
+        return nil
+    }
+    delete(c.cbs, path)
+    delete(c.md5s, path)
+    // 文件已经被删除时fsnotify已经不再监听
+    _ = c.w.Remove(path)
+    return nil
+}
+
+// WatchDir 监听目录中文件的添加和删除
+func (c *fileClient) WatchDir(dir string, opts *DirOptions, cb DirChangedCallback) error {
+    if opts == nil {
+        opts = &DirOptions{}
+    }
+    dir = filepath.Clean(dir)
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if c.w == nil {
+        return pkgerrs.New("watch disabled")
+    }
+    if _, ok := c.dirs[dir]; ok {
+        return nil
+    }
+    dw := &dirWatch{root: dir, opts: opts, cb: cb, files: map[string]bool{}}
+    files, _ := ListDir(dir, opts)
+    for _, f := range files {
+        dw.files[f] = true
+    }
+    if err := c.addDirs(dir, opts.Recursive); err != nil {
+        return err
+    }
+    c.dirs[dir] = dw
+    return nil
+}
+
+// addDirs 监听目录, recursive时同时监听子目录
+func (c *fileClient) addDirs(dir string, recursive bool) error {
+    if !recursive {
+        return c.w.Add(dir)
+    }
+    return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+        if err != nil {
+            return err
+        }
+        if !info.IsDir() {
+            return nil
+        }
+        if path != dir && isHidden(path) {
+            return filepath.SkipDir
+        }
+        return c.w.Add(path)
+    })
+}
+
+func (c *fileClient) dirOf(name string) *dirWatch {
+    for _, dw := range c.dirs {
+        if dw.contains(name) {
+            return dw
+        }
+    }
+    return nil
+}
+
+// 目录中创建了文件或者子目录
+func (c *fileClient) handleDirCreated(dw *dirWatch, name string) (added []string) {
+    info, err := os.Stat(name)
+    if err != nil || isHidden(name) {
+        return nil
+    }
+    var files []string
+    if info.IsDir() {
+        if !dw.opts.Recursive {
+            return nil
+        }
+        if err = c.addDirs(name, true); err != nil {
+            log.Get().Warnf("Can't watch directory %q: %v", name, err)
+        }
+        files, err = ListDir(name, dw.opts)
+        if err != nil {
+            log.Get().Errorf("Can't list directory %q: %v", name, err)
+        }
+    } else {
+        if !dw.opts.match(name) {
+            return nil
+        }
+        if err = checkFileSize(name, info, dw.opts); err != nil {
+            log.Get().Errorf("Can't add config file: %v", err)
+            return nil
+        }
+        files = []string{name}
+    }
+    for _, f := range files {
+        if !dw.files[f] {
+            dw.files[f] = true
+            added = append(added, f)
+        }
+    }
+    return added
+}
+
+// 处理监听目录中的文件添加和删除, readable为删除事件后文件是否仍然可读
+func (c *fileClient) handleDirEvents(event fsnotify.Event, readable bool) {
+    var added, removed []string
+    c.mu.Lock()
+    dw := c.dirOf(event.Name)
+    if dw == nil {
+        c.mu.Unlock()
+        return
+    }
+    if event.Op&fsnotify.Create == fsnotify.Create {
+        added = c.handleDirCreated(dw, event.Name)
+    } else if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 && !readable {
+        // 文件被替换(如编辑器保存)时, 不认为被删除
+        removed = dw.remove(event.Name)
+    }
+    cb := dw.cb
+    c.mu.Unlock()
+    if cb != nil && (len(added) > 0 || len(removed) > 0) {
+        cb(added, removed)
+    }
+}
+
 // 文件内容发生变化
 func (c *fileClient) handleUpdated(filePath string) {
     c.mu.Lock()
-    defer c.mu.Unlock()
     cb, _ := c.cbs[filePath]
     if cb == nil {
+        c.mu.Unlock()
         return
     }
     data, err := ioutil.ReadFile(filePath)
     if err != nil {
         log.Get().Debugf("Can't read file %q content: %v", filePath, err)
+    } else {
+        // 内容没有变化, 不需要通知
+        m5 := md5.New()
+        m5.Write(data)
+        sum := hex.EncodeToString(m5.Sum(nil))
+        if c.md5s[filePath] == sum {
+            c.mu.Unlock()
+            return
+        }
+        c.md5s[filePath] = sum
     }
+    c.mu.Unlock()
     cb(data)
 }
 
+// 文件已经被删除, 不再监听
+func (c *fileClient) removeWatch(filePath string) {
+    c.mu.Lock()
+    delete(c.cbs, filePath)
+    delete(c.md5s, filePath)
+    c.mu.Unlock()
+    _ = c.w.Remove(filePath)
+}
+
 func (c *fileClient) isFileCanRead(fileName string) bool {
     if f, err := os.Open(fileName); err == nil && f != nil {
         _ = f.Close()
@@ -122,10 +293,16 @@ func (c *fileClient) isFileCanRead(fileName string) bool {
 
 // 处理文件系统事件
 func (c *fileClient) handleEvents(event fsnotify.Event) {
-    if event.Op&fsnotify.Remove == fsnotify.Remove {
+    readable := false
+    if event.Op&(fsnotify.Remove|fsnotify.Chmod|fsnotify.Rename|fsnotify.Write) != 0 {
+        // 等待文件被替换(如编辑器保存)完成
         time.Sleep(50 * time.Millisecond)
-        if !c.isFileCanRead(event.Name) {
-            c.w.Remove(event.Name)
+        readable = c.isFileCanRead(event.Name)
+    }
+    c.handleDirEvents(event, readable)
+    if event.Op&fsnotify.Remove == fsnotify.Remove {
+        if !readable {
+            c.removeWatch(event.Name)
         } else {
             c.w.Add(event.Name)
             c.handleUpdated(event.Name)
@@ -133,21 +310,18 @@ func (c *fileClient) handleEvents(event fsnotify.Event) {
         return
     }
     if event.Op&fsnotify.Chmod == fsnotify.Chmod {
-        time.Sleep(50 * time.Millisecond)
-        if !c.isFileCanRead(event.Name) {
-            c.w.Remove(event.Name)
+        if !readable {
+            c.removeWatch(event.Name)
         }
         return
     }
     if event.Op&fsnotify.Rename == fsnotify.Rename {
-        time.Sleep(50 * time.Millisecond)
-        if !c.isFileCanRead(event.Name) {
-            c.w.Remove(event.Name)
+        if !readable {
+            c.removeWatch(event.Name)
         }
         return
     }
     if event.Op&fsnotify.Write == fsnotify.Write {
-        time.Sleep(50 * time.Millisecond)
         c.handleUpdated(event.Name)
     }
 }
@@ -184,6 +358,7 @@ func newFileClient(cfg *Config) (Client, error) {
     c := &fileClient{
         md5s: map[string]string{},
         cbs:  map[string]func(data []byte){},
+        dirs: map[string]*dirWatch{},
         w:    createWatcher(cfg.WatchDisabled),
     }
     go c.start()
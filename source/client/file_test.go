@@ -0,0 +1,90 @@
+package client
+
+import (
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "sort"
+    "testing"
+    "time"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func writeTestFile(t *testing.T, name string, size int) {
+    assert.NoError(t, os.MkdirAll(filepath.Dir(name), 0755))
+    assert.NoError(t, ioutil.WriteFile(name, make([]byte, size), 0644))
+}
+
+func TestListDir(t *testing.T) {
+    dir, err := ioutil.TempDir("", "vade-list-dir")
+    assert.NoError(t, err)
+    defer os.RemoveAll(dir)
+
+    writeTestFile(t, filepath.Join(dir, "a.yaml"), 10)
+    writeTestFile(t, filepath.Join(dir, "b.json"), 10)
+    writeTestFile(t, filepath.Join(dir, ".a.yaml.swp"), 10)
+    writeTestFile(t, filepath.Join(dir, "sub", "c.yaml"), 10)
+    writeTestFile(t, filepath.Join(dir, "large.yaml"), 100)
+
+    files, err := ListDir(dir, &DirOptions{Pattern: "*.yaml", MaxFileSize: 50})
+    assert.Equal(t, []string{filepath.Join(dir, "a.yaml")}, files)
+    _, ok := err.(*FileTooLargeError)
+    assert.True(t, ok, "should be FileTooLargeError")
+
+    files, err = ListDir(dir, &DirOptions{Recursive: true})
+    assert.NoError(t, err)
+    sort.Strings(files)
+    assert.Equal(t, []string{
+        filepath.Join(dir, "a.yaml"),
+        filepath.Join(dir, "b.json"),
+        filepath.Join(dir, "large.yaml"),
+        filepath.Join(dir, "sub", "c.yaml"),
+    }, files)
+
+    // 没有目录选项时包含隐藏文件, 超过大小限制的文件只跳过
+    writeTestFile(t, filepath.Join(dir, "huge.yaml"), DefaultMaxFileSize+1)
+    files, err = ListDir(dir, nil)
+    assert.NoError(t, err)
+    sort.Strings(files)
+    assert.Equal(t, []string{
+        filepath.Join(dir, ".a.yaml.swp"),
+        filepath.Join(dir, "a.yaml"),
+        filepath.Join(dir, "b.json"),
+        filepath.Join(dir, "large.yaml"),
+    }, files)
+}
+
+func TestFileClientWatchDir(t *testing.T) {
+    dir, err := ioutil.TempDir("", "vade-watch-dir")
+    assert.NoError(t, err)
+    defer os.RemoveAll(dir)
+
+    c, err := newFileClient(DefaultConfig())
+    assert.NoError(t, err)
+    defer c.Close()
+
+    type change struct{ added, removed []string }
+    changes := make(chan change, 10)
+    err = c.(DirWatcher).WatchDir(dir, &DirOptions{Pattern: "*.yaml"}, func(added, removed []string) {
+        changes <- change{added, removed}
+    })
+    assert.NoError(t, err)
+
+    name := filepath.Join(dir, "a.yaml")
+    writeTestFile(t, filepath.Join(dir, "ignored.txt"), 1)
+    writeTestFile(t, name, 1)
+    select {
+    case ch := <-changes:
+        assert.Equal(t, []string{name}, ch.added)
+    case <-time.After(3 * time.Second):
+        t.Fatal("wait added timeout")
+    }
+    assert.NoError(t, os.Remove(name))
+    select {
+    case ch := <-changes:
+        assert.Equal(t, []string{name}, ch.removed)
+    case <-time.After(3 * time.Second):
+        t.Fatal("wait removed timeout")
+    }
+}
@@ -4,69 +4,133 @@ import (
     "context"
     "flag"
     "os"
-    "time"
 
+    "github.com/spf13/pflag"
     "gopkg.in/yaml.v2"
 )
 
 const Flag = "flag"
 
 var (
-    _ Client = (*flagClient)(nil)
+    _ Client    = (*flagClient)(nil)
+    _ Formatter = (*flagClient)(nil)
 )
 
 func init() {
     _ = RegisterClient(Flag, newFlagClient)
 }
 
+// flagClient 读取命令行中显式设置的参数, 没有设置的参数不会覆盖其他配置
 type flagClient struct {
-    flags   map[string]interface{}
-    flagSet *flag.FlagSet
+    flagSet  *flag.FlagSet
+    pflagSet *pflag.FlagSet
+    args     []string
 }
 
 func (c *flagClient) Close() error { return nil }
 func (c *flagClient) Pull(ctx context.Context, path string) (data []byte, err error) {
-    if !c.flagSet.Parsed() {
-        _ = c.flagSet.Parse(os.Args[1:])
-    }
     ps := map[string]interface{}{}
-    c.flagSet.Visit(func(f *flag.Flag) {
-        if v, ok := c.flags[f.Name]; ok && v != nil {
-            key := f.Name
-            switch v.(type) {
-            case *string:
-                ps[key] = *(v.(*string))
-            case *int:
-                ps[key] = *(v.(*int))
-            case *uint:
-                ps[key] = *(v.(*int))
-            case *float64:
-                ps[key] = *(v.(*float64))
-            case *time.Duration:
-                ps[key] = *(v.(*time.Duration))
-            case *int64:
-                ps[key] = *(v.(*int64))
-            case *uint64:
-                ps[key] = *(v.(*uint64))
-            case *bool:
-                ps[key] = *(v.(*bool))
+    if c.pflagSet != nil {
+        if !c.pflagSet.Parsed() {
+            if err = c.pflagSet.Parse(c.args); err != nil {
+                return nil, err
+            }
+        }
+        c.pflagSet.Visit(func(f *pflag.Flag) {
+            ps[f.Name] = pflagValue(c.pflagSet, f)
+        })
+    } else {
+        if !c.flagSet.Parsed() {
+            if err = c.flagSet.Parse(c.args); err != nil {
+                return nil, err
             }
         }
-    })
+        c.flagSet.Visit(func(f *flag.Flag) {
+            if g, ok := f.Value.(flag.Getter); ok {
+                ps[f.Name] = g.Get()
+            } else {
+                ps[f.Name] = f.Value.String()
+            }
+        })
+    }
     data, err = yaml.Marshal(ps)
     return
 }
+func (c *flagClient) Format(path string) string { return "yaml" }
 func (c *flagClient) Push(ctx context.Context, path string, data []byte) error { return nil }
 func (c *flagClient) Watch(path string, cb ChangedCallback) error {
     return nil
 }
 
+// pflagValue 根据pflag参数的类型获取参数的值
+func pflagValue(fs *pflag.FlagSet, f *pflag.Flag) interface{} {
+    var (
+        v   interface{}
+        err error
+    )
+    switch f.Value.Type() {
+    case "bool":
+        v, err = fs.GetBool(f.Name)
+    case "int":
+        v, err = fs.GetInt(f.Name)
+    case "int8":
+        v, err = fs.GetInt8(f.Name)
+    case "int16":
+        v, err = fs.GetInt16(f.Name)
+    case "int32":
+        v, err = fs.GetInt32(f.Name)
+    case "int64":
+        v, err = fs.GetInt64(f.Name)
+    case "uint":
+        v, err = fs.GetUint(f.Name)
+    case "uint8":
+        v, err = fs.GetUint8(f.Name)
+    case "uint16":
+        v, err = fs.GetUint16(f.Name)
+    case "uint32":
+        v, err = fs.GetUint32(f.Name)
+    case "uint64":
+        v, err = fs.GetUint64(f.Name)
+    case "float32":
+        v, err = fs.GetFloat32(f.Name)
+    case "float64":
+        v, err = fs.GetFloat64(f.Name)
+    case "duration":
+        v, err = fs.GetDuration(f.Name)
+    case "stringSlice":
+        v, err = fs.GetStringSlice(f.Name)
+    case "stringArray":
+        v, err = fs.GetStringArray(f.Name)
+    case "intSlice":
+        v, err = fs.GetIntSlice(f.Name)
+    case "int64Slice":
+        v, err = fs.GetInt64Slice(f.Name)
+    case "uintSlice":
+        v, err = fs.GetUintSlice(f.Name)
+    case "float64Slice":
+        v, err = fs.GetFloat64Slice(f.Name)
+    case "boolSlice":
+        v, err = fs.GetBoolSlice(f.Name)
+    case "durationSlice":
+        v, err = fs.GetDurationSlice(f.Name)
+    case "stringToString":
+        v, err = fs.GetStringToString(f.Name)
+    default:
+        return f.Value.String()
+    }
+    if err != nil {
+        return f.Value.String()
+    }
+    return v
+}
+
 func newFlagClient(cfg *Config) (Client, error) {
-    if cfg.FlagSet == nil {
+    if cfg.FlagSet == nil && cfg.PFlagSet == nil {
         cfg.FlagSet = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
     }
-    if cfg.Flags == nil {
-        cfg.Flags = make(map[string]interface{})
+    args := cfg.Args
+    if args == nil {
+        args = os.Args[1:]
     }
-    return &flagClient{flags: cfg.Flags, flagSet: cfg.FlagSet}, nil
+    return &flagClient{flagSet: cfg.FlagSet, pflagSet: cfg.PFlagSet, args: args}, nil
 }
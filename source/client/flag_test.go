@@ -0,0 +1,48 @@
+package client
+
+import (
+    "context"
+    "flag"
+    "testing"
+    "time"
+
+    "github.com/spf13/pflag"
+    "github.com/stretchr/testify/assert"
+    "gopkg.in/yaml.v2"
+)
+
+func pullFlags(t *testing.T, cfg *Config) map[string]interface{} {
+    c, err := newFlagClient(cfg)
+    assert.NoError(t, err)
+    data, err := c.Pull(context.Background(), "default")
+    assert.NoError(t, err)
+    values := map[string]interface{}{}
+    assert.NoError(t, yaml.Unmarshal(data, &values))
+    return values
+}
+
+func TestFlagClientPull(t *testing.T) {
+    fs := flag.NewFlagSet("test", flag.ContinueOnError)
+    fs.Uint("workers", 1, "")
+    fs.Int64("max", 1, "")
+    fs.Duration("timeout", time.Second, "")
+    fs.String("unset", "x", "")
+    assert.NoError(t, fs.Parse([]string{"-workers=4", "-max=10", "-timeout=3s"}))
+
+    values := pullFlags(t, &Config{FlagSet: fs})
+    assert.Equal(t, map[string]interface{}{"workers": 4, "max": 10, "timeout": "3s"}, values)
+}
+
+func TestFlagClientPullPFlag(t *testing.T) {
+    fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+    fs.Int("server.port", 80, "")
+    fs.StringSlice("hosts", nil, "")
+    fs.Bool("debug", false, "")
+    assert.NoError(t, fs.Parse([]string{"--server.port=8080", "--hosts=a,b"}))
+
+    values := pullFlags(t, &Config{PFlagSet: fs})
+    assert.Equal(t, map[string]interface{}{
+        "server.port": 8080,
+        "hosts":       []interface{}{"a", "b"},
+    }, values)
+}
@@ -5,7 +5,9 @@ import (
     "crypto/md5"
     "encoding/hex"
     "io/ioutil"
+    pathpkg "path"
     "sync"
+    "time"
 
     nacosconts "github.com/nacos-group/nacos-sdk-go/common/constant"
     "github.com/pkg/errors"
@@ -16,15 +18,22 @@ import (
 
     stdlog "log"
 
+    "github.com/derry6/vade-go/pkg/log"
     "github.com/derry6/vade-go/source/client"
 )
 
 const (
     Name = "nacos"
+
+    // 查询配置type失败后的重试间隔
+    typeRetryInterval = time.Minute
 )
 
 var (
-    _ client.Client = (*Client)(nil)
+    _ client.Client         = (*Client)(nil)
+    _ client.Formatter      = (*Client)(nil)
+    _ client.ResponsePuller = (*Client)(nil)
+    _ client.Unwatcher      = (*Client)(nil)
 )
 
 func init() {
@@ -38,6 +47,10 @@ type Client struct {
     mutex         sync.RWMutex
     client        nacoscc.IConfigClient
     callbacks     map[string]client.ChangedCallback
+    listened      map[string]bool // sdk不支持取消监听, 重新监听时复用
+    meta          *metaClient
+    types         map[string]string    // dataId@group@namespace -> type
+    retries       map[string]time.Time // 查询type失败后, 下次重试的时间
 }
 
 func (c *Client) Close() error { return nil }
@@ -51,6 +64,66 @@ func (c *Client) Pull(ctx context.Context, path string) (data []byte, err error)
     return []byte(content), err
 }
 
+// PullResponse 格式为配置的type元信息, 版本为配置内容的md5
+func (c *Client) PullResponse(ctx context.Context, path string) (*client.Response, error) {
+    data, err := c.Pull(ctx, path)
+    if err != nil {
+        return nil, err
+    }
+    p := newPath(path, c.group, c.namespace)
+    if p.typ == "" && pathpkg.Ext(p.dataId) == "" {
+        c.lookupType(ctx, p)
+    }
+    return &client.Response{
+        Data:    data,
+        Format:  c.Format(path),
+        Version: c.md5(string(data)),
+    }, nil
+}
+
+// lookupType 查询并缓存配置的type, 失败后typeRetryInterval内不再查询
+func (c *Client) lookupType(ctx context.Context, p *nPath) {
+    key := p.String()
+    c.mutex.RLock()
+    _, ok := c.types[key]
+    retry := c.retries[key]
+    c.mutex.RUnlock()
+    if ok || time.Now().Before(retry) {
+        return
+    }
+    typ, err := c.meta.configType(ctx, p)
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    if err != nil {
+        log.Get().Warnf("Can not get nacos config type of %q: %v", key, err)
+        c.retries[key] = time.Now().Add(typeRetryInterval)
+        return
+    }
+    delete(c.retries, key)
+    c.types[key] = typ
+}
+
+// Format 依次使用path中的type参数, dataId的扩展名, 配置的type元信息, 都没有时自动检测
+func (c *Client) Format(path string) string {
+    p := newPath(path, c.group, c.namespace)
+    if p == nil {
+        return ""
+    }
+    if p.typ != "" {
+        return p.typ
+    }
+    if pathpkg.Ext(p.dataId) != "" {
+        return p.Ext()
+    }
+    c.mutex.RLock()
+    typ, _ := c.types[p.String()]
+    c.mutex.RUnlock()
+    if typ == "text" {
+        return ""
+    }
+    return typ
+}
+
 func (c *Client) Push(ctx context.Context, path string, data []byte) error {
     p := newPath(path, c.group, c.namespace)
     if p.dataId == "" {
@@ -77,6 +150,9 @@ func (c *Client) Watch(path string, cb client.ChangedCallback) error {
         return nil
     }
     c.callbacks[paId] = cb
+    if c.listened[paId] {
+        return nil
+    }
     param := nacosvo.ConfigParam{
         DataId:   p.dataId,
         Group:    p.group,
@@ -85,6 +161,16 @@ func (c *Client) Watch(path string, cb client.ChangedCallback) error {
     if err := c.client.ListenConfig(param); err != nil {
         return err
     }
+    c.listened[paId] = true
+    return nil
+}
+
+// Unwatch 删除path的回调
+func (c *Client) Unwatch(path string) error {
+    p := newPath(path, c.group, c.namespace)
+    c.mutex.Lock()
+    delete(c.callbacks, p.String())
+    c.mutex.Unlock()
     return nil
 }
 
@@ -134,6 +220,10 @@ func NewClient(cfg *client.Config) (client.Client, error) {
         namespace:     namespaceId,
         client:        configClient,
         callbacks:     make(map[string]client.ChangedCallback),
+        listened:      make(map[string]bool),
+        meta:          newMetaClient(sConfigs, cfg),
+        types:         make(map[string]string),
+        retries:       make(map[string]time.Time),
         watchDisabled: cfg.WatchDisabled,
     }
     return s, nil
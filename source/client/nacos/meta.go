@@ -0,0 +1,154 @@
+package nacos
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "net/http"
+    "net/url"
+    "strings"
+    "sync"
+    "time"
+
+    nacosconts "github.com/nacos-group/nacos-sdk-go/common/constant"
+    "github.com/pkg/errors"
+
+    "github.com/derry6/vade-go/source/client"
+)
+
+// metaClient 通过nacos的open api查询配置的元信息, sdk不返回配置的type
+type metaClient struct {
+    servers  []nacosconts.ServerConfig
+    username string
+    password string
+    cli      *http.Client
+    tokens   map[string]*accessToken // 按server缓存的accessToken
+    mutex    sync.Mutex
+}
+
+type accessToken struct {
+    token  string
+    expire time.Time
+}
+
+type configMeta struct {
+    DataId string `json:"dataId"`
+    Group  string `json:"group"`
+    Type   string `json:"type"`
+    Md5    string `json:"md5"`
+}
+
+func (m *metaClient) configType(ctx context.Context, p *nPath) (typ string, err error) {
+    values := url.Values{}
+    values.Set("show", "all")
+    values.Set("dataId", p.dataId)
+    values.Set("group", p.group)
+    if p.namespace != defaultNamespace {
+        values.Set("tenant", p.namespace)
+    }
+    for _, s := range m.servers {
+        base := fmt.Sprintf("http://%s:%d%s", s.IpAddr, s.Port, s.ContextPath)
+        var meta *configMeta
+        if meta, err = m.get(ctx, base, values); err == nil {
+            return meta.Type, nil
+        }
+    }
+    return "", err
+}
+
+// login 和sdk一样使用用户名和密码换取accessToken, 密码只在POST的body中发送
+func (m *metaClient) login(ctx context.Context, base string) (string, error) {
+    if m.username == "" {
+        return "", nil
+    }
+    m.mutex.Lock()
+    t := m.tokens[base]
+    m.mutex.Unlock()
+    if t != nil && time.Now().Before(t.expire) {
+        return t.token, nil
+    }
+    form := url.Values{}
+    form.Set("username", m.username)
+    form.Set("password", m.password)
+    req, err := http.NewRequest(http.MethodPost, base+"/v1/auth/login", strings.NewReader(form.Encode()))
+    if err != nil {
+        return "", err
+    }
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    body, err := m.do(ctx, req)
+    if err != nil {
+        return "", errors.Wrap(err, "login")
+    }
+    rsp := struct {
+        AccessToken string `json:"accessToken"`
+        TokenTTL    int64  `json:"tokenTtl"`
+    }{}
+    if err = json.Unmarshal(body, &rsp); err != nil {
+        return "", errors.Wrap(err, "login")
+    }
+    // 提前刷新accessToken
+    ttl := time.Duration(rsp.TokenTTL) * time.Second * 9 / 10
+    m.mutex.Lock()
+    m.tokens[base] = &accessToken{token: rsp.AccessToken, expire: time.Now().Add(ttl)}
+    m.mutex.Unlock()
+    return rsp.AccessToken, nil
+}
+
+func (m *metaClient) get(ctx context.Context, base string, values url.Values) (*configMeta, error) {
+    token, err := m.login(ctx, base)
+    if err != nil {
+        return nil, err
+    }
+    if token != "" {
+        query := url.Values{}
+        for k, v := range values {
+            query[k] = v
+        }
+        query.Set("accessToken", token)
+        values = query
+    }
+    req, err := http.NewRequest(http.MethodGet, base+"/v1/cs/configs?"+values.Encode(), nil)
+    if err != nil {
+        return nil, err
+    }
+    body, err := m.do(ctx, req)
+    if err != nil {
+        return nil, err
+    }
+    meta := &configMeta{}
+    if err = json.Unmarshal(body, meta); err != nil {
+        return nil, err
+    }
+    return meta, nil
+}
+
+func (m *metaClient) do(ctx context.Context, req *http.Request) ([]byte, error) {
+    rsp, err := m.cli.Do(req.WithContext(ctx))
+    if err != nil {
+        return nil, err
+    }
+    defer rsp.Body.Close()
+    body, err := ioutil.ReadAll(rsp.Body)
+    if err != nil {
+        return nil, err
+    }
+    if rsp.StatusCode != http.StatusOK {
+        return nil, errors.Errorf("status code is %d", rsp.StatusCode)
+    }
+    return body, nil
+}
+
+func newMetaClient(servers []nacosconts.ServerConfig, cfg *client.Config) *metaClient {
+    timeout := cfg.Timeout
+    if timeout == 0 {
+        timeout = time.Second
+    }
+    return &metaClient{
+        servers:  servers,
+        username: cfg.Username,
+        password: cfg.Password,
+        cli:      &http.Client{Timeout: timeout},
+        tokens:   map[string]*accessToken{},
+    }
+}
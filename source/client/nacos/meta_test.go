@@ -0,0 +1,121 @@
+package nacos
+
+import (
+    "context"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/stretchr/testify/assert"
+
+    "github.com/derry6/vade-go/source/client"
+)
+
+// fakeServer 模拟nacos的登录和配置元信息接口
+type fakeServer struct {
+    mu      sync.Mutex
+    logins  int
+    queries int
+    token   string
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    switch r.URL.Path {
+    case "/nacos/v1/auth/login":
+        if r.Method != http.MethodPost || r.PostFormValue("username") != "nacos" || r.PostFormValue("password") != "secret" {
+            w.WriteHeader(http.StatusForbidden)
+            return
+        }
+        s.logins++
+        s.token = fmt.Sprintf("token-%d", s.logins)
+        _, _ = fmt.Fprintf(w, `{"accessToken":%q,"tokenTtl":18000}`, s.token)
+    case "/nacos/v1/cs/configs":
+        s.queries++
+        q := r.URL.Query()
+        if q.Get("accessToken") != s.token || q.Get("show") != "all" {
+            w.WriteHeader(http.StatusForbidden)
+            return
+        }
+        if q.Get("dataId") == "missing" {
+            w.WriteHeader(http.StatusNotFound)
+            return
+        }
+        _, _ = fmt.Fprintf(w, `{"dataId":%q,"group":%q,"type":"yaml"}`, q.Get("dataId"), q.Get("group"))
+    default:
+        w.WriteHeader(http.StatusNotFound)
+    }
+}
+
+func newFakeMeta(password string) (*fakeServer, *metaClient, func()) {
+    s := &fakeServer{}
+    srv := httptest.NewServer(s)
+    cfg := client.DefaultConfig()
+    cfg.Address = srv.URL
+    cfg.Username = "nacos"
+    cfg.Password = password
+    return s, newMetaClient(getServerConfigs(cfg), cfg), srv.Close
+}
+
+func TestMetaConfigType(t *testing.T) {
+    s, m, closeFn := newFakeMeta("secret")
+    defer closeFn()
+    p := newPath("app", defaultGroup, defaultNamespace)
+
+    typ, err := m.configType(context.TODO(), p)
+    assert.NoError(t, err)
+    assert.Equal(t, "yaml", typ)
+    _, err = m.configType(context.TODO(), p)
+    assert.NoError(t, err)
+    assert.Equal(t, 1, s.logins)
+
+    // accessToken过期后重新登录
+    m.mutex.Lock()
+    for _, token := range m.tokens {
+        token.expire = time.Now().Add(-time.Second)
+    }
+    m.mutex.Unlock()
+    typ, err = m.configType(context.TODO(), p)
+    assert.NoError(t, err)
+    assert.Equal(t, "yaml", typ)
+    assert.Equal(t, 2, s.logins)
+}
+
+func TestMetaLoginFailed(t *testing.T) {
+    s, m, closeFn := newFakeMeta("wrong")
+    defer closeFn()
+    _, err := m.configType(context.TODO(), newPath("app", defaultGroup, defaultNamespace))
+    assert.Error(t, err)
+    assert.Equal(t, 0, s.queries)
+}
+
+func TestLookupTypeRetry(t *testing.T) {
+    s, m, closeFn := newFakeMeta("secret")
+    defer closeFn()
+    c := &Client{
+        group:     defaultGroup,
+        namespace: defaultNamespace,
+        meta:      m,
+        types:     map[string]string{},
+        retries:   map[string]time.Time{},
+    }
+    c.lookupType(context.TODO(), newPath("app", c.group, c.namespace))
+    c.lookupType(context.TODO(), newPath("app", c.group, c.namespace))
+    assert.Equal(t, "yaml", c.Format("app"))
+    assert.Equal(t, 1, s.queries)
+
+    // 查询失败后等待重试间隔
+    missing := newPath("missing", c.group, c.namespace)
+    c.lookupType(context.TODO(), missing)
+    c.lookupType(context.TODO(), missing)
+    assert.Equal(t, 2, s.queries)
+    assert.Equal(t, "", c.Format("missing"))
+
+    c.retries[missing.String()] = time.Now().Add(-time.Second)
+    c.lookupType(context.TODO(), missing)
+    assert.Equal(t, 3, s.queries)
+}
@@ -10,6 +10,7 @@ type nPath struct {
     namespace string
     group     string
     dataId    string
+    typ       string // 配置的类型, 如yaml, json, properties
 }
 
 func (p *nPath) String() string {
@@ -47,5 +48,6 @@ func newPath(path string, defaultGroup, defaultNamespace string) *nPath {
     if ns := q.Get("namespace"); ns != "" {
         p.namespace = ns
     }
+    p.typ = q.Get("type")
     return p
 }
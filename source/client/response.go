@@ -0,0 +1,55 @@
+package client
+
+import (
+    "context"
+)
+
+// Response 包含格式和版本信息的配置内容
+type Response struct {
+    Data    []byte
+    Format  string // 配置的格式(扩展名), 如yaml, json, properties, 为空时根据path选择或者自动检测
+    Version string // 配置的版本, 如apollo的releaseKey, etcd的revision
+}
+
+// ResponseCallback 配置变化的回调
+type ResponseCallback func(rsp *Response)
+
+// ResponsePuller 拉取配置时返回配置的格式和版本
+type ResponsePuller interface {
+    PullResponse(ctx context.Context, path string) (*Response, error)
+}
+
+// ResponseWatcher 监听配置时返回配置的格式和版本
+type ResponseWatcher interface {
+    WatchResponse(path string, cb ResponseCallback) error
+}
+
+func formatOf(c Client, path string) string {
+    if f, ok := c.(Formatter); ok {
+        return f.Format(path)
+    }
+    return ""
+}
+
+// PullResponse 拉取配置, 客户端没有实现ResponsePuller时, 使用Formatter返回的格式
+func PullResponse(ctx context.Context, c Client, path string) (*Response, error) {
+    if p, ok := c.(ResponsePuller); ok {
+        return p.PullResponse(ctx, path)
+    }
+    data, err := c.Pull(ctx, path)
+    if err != nil {
+        return nil, err
+    }
+    return &Response{Data: data, Format: formatOf(c, path)}, nil
+}
+
+// WatchResponse 监听配置, 客户端没有实现ResponseWatcher时, 使用Formatter返回的格式
+func WatchResponse(c Client, path string, cb ResponseCallback) error {
+    if w, ok := c.(ResponseWatcher); ok {
+        return w.WatchResponse(path, cb)
+    }
+    format := formatOf(c, path)
+    return c.Watch(path, func(data []byte) {
+        cb(&Response{Data: data, Format: format})
+    })
+}
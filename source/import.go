@@ -0,0 +1,213 @@
+package source
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	pkgerrs "github.com/pkg/errors"
+	"github.com/spf13/cast"
+
+	"github.com/derry6/vade-go/pkg/log"
+)
+
+// ImportKey 配置中声明导入其他配置的key, 例如:
+//
+//	vade.import: [common.yaml, "nacos:shared.yaml"]
+const ImportKey = "vade.import"
+
+// ImportFunc 将path导入到名称为source的配置源中
+type ImportFunc func(source string, path string, opts ...PathOption) error
+
+// UnimportFunc 取消导入名称为source的配置源中的path
+type UnimportFunc func(source string, path string) error
+
+// importRef 已解析的导入
+type importRef struct {
+	source string
+	path   string
+	opts   []PathOption
+}
+
+// OnImport 设置跨source导入和取消导入的回调, 已添加的path中声明的跨source导入会立即执行
+func (bs *BaseSource) OnImport(add ImportFunc, remove UnimportFunc) {
+	bs.mutex.Lock()
+	bs.importer, bs.unimporter = add, remove
+	var refs []importRef
+	for _, store := range bs.stores {
+		for _, r := range store.imports {
+			if r.source != bs.name {
+				refs = append(refs, r)
+			}
+		}
+	}
+	bs.mutex.Unlock()
+	if add == nil {
+		return
+	}
+	for _, r := range refs {
+		if err := add(r.source, r.path, r.opts...); err != nil {
+			log.Get().Warnf("Can't import %q from source %q: %v", r.path, r.source, err)
+		}
+	}
+}
+
+func (bs *BaseSource) importKey() string {
+	if n := len(bs.prefix); n > 0 {
+		if bs.prefix[n-1] == '.' {
+			return bs.normalizeKey(bs.prefix + ImportKey)
+		}
+		return bs.normalizeKey(bs.prefix + "." + ImportKey)
+	}
+	return bs.normalizeKey(ImportKey)
+}
+
+// importsOf 获取配置中声明的导入, 支持列表和逗号分隔的字符串
+func (bs *BaseSource) importsOf(values map[string]interface{}) (imports []string) {
+	key := bs.importKey()
+	v, ok := values[key]
+	if !ok {
+		return nil
+	}
+	var items []string
+	switch x := v.(type) {
+	case int:
+		for i := 0; i < x; i++ {
+			if item, ok := values[key+"["+strconv.Itoa(i)+"]"]; ok {
+				items = append(items, cast.ToString(item))
+			}
+		}
+	case string:
+		items = strings.Split(x, ",")
+	}
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			imports = append(imports, item)
+		}
+	}
+	return imports
+}
+
+// resolveImport 解析导入的source和path, 本source中的path相对于导入者所在的目录
+func (bs *BaseSource) resolveImport(from string, imp string) (source string, path string) {
+	if i := strings.Index(imp, ":"); i > 0 && !strings.ContainsAny(imp[:i], `/\.`) {
+		source, imp = imp[:i], imp[i+1:]
+	}
+	if source != "" && source != bs.name {
+		return source, imp
+	}
+	if filepath.IsAbs(imp) {
+		return bs.name, imp
+	}
+	if dir := filepath.Dir(from); dir != "." {
+		return bs.name, filepath.Join(dir, imp)
+	}
+	return bs.name, imp
+}
+
+func importID(source, path string) string {
+	return source + ":" + path
+}
+
+func hasImport(refs []importRef, r importRef) bool {
+	for _, x := range refs {
+		if x.source == r.source && x.path == r.path {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveImports 解析store中声明的导入, 循环导入会被跳过并返回错误
+func (bs *BaseSource) resolveImports(store *pathStore, values map[string]interface{}) (refs []importRef, err error) {
+	if bs.importDisabled {
+		return nil, nil
+	}
+	chain := append(append([]string{}, store.opts.importChain...), importID(bs.name, store.path))
+	for _, imp := range bs.importsOf(values) {
+		source, path := bs.resolveImport(store.path, imp)
+		id := importID(source, path)
+		circular := false
+		for _, c := range chain {
+			circular = circular || c == id
+		}
+		if circular {
+			if err == nil {
+				err = pkgerrs.Errorf("circular import was detected: %s -> %s", strings.Join(chain, " -> "), id)
+			}
+			continue
+		}
+		opts := []PathOption{WithPathPriority(store.pri - 1), withImportChain(chain)}
+		if store.opts.required {
+			opts = append(opts, WithPathRequired())
+		}
+		if store.opts.watchDisabled {
+			opts = append(opts, WithPathWatchDisabled())
+		}
+		refs = append(refs, importRef{source: source, path: path, opts: opts})
+	}
+	return refs, err
+}
+
+// addImports 更新store的导入, 添加新声明的导入, 删除不再声明的导入。
+// 导入的path优先级低于导入者。
+func (bs *BaseSource) addImports(store *pathStore, refs []importRef) (err error) {
+	bs.mutex.Lock()
+	old := store.imports
+	store.imports = refs
+	cb := bs.importer
+	bs.mutex.Unlock()
+	for _, r := range old {
+		if !hasImport(refs, r) {
+			bs.unimport(r)
+		}
+	}
+	for _, r := range refs {
+		if hasImport(old, r) {
+			continue
+		}
+		var e error
+		if r.source == bs.name {
+			e = bs.AddPath(r.path, r.opts...)
+		} else if cb != nil {
+			// 未设置回调时, 在OnImport时导入
+			e = cb(r.source, r.path, r.opts...)
+		}
+		if e != nil && err == nil {
+			err = pkgerrs.Wrapf(e, "import %q", importID(r.source, r.path))
+		}
+	}
+	return err
+}
+
+func (bs *BaseSource) unimport(r importRef) {
+	var err error
+	if r.source == bs.name {
+		err = bs.RemoveImport(r.path)
+	} else {
+		bs.mutex.RLock()
+		cb := bs.unimporter
+		bs.mutex.RUnlock()
+		if cb != nil {
+			err = cb(r.source, r.path)
+		}
+	}
+	if err != nil {
+		log.Get().Warnf("Can't remove import %q from source %q: %v", r.path, r.source, err)
+	}
+}
+
+// RemoveImport 删除导入的path, path不是导入添加的或者仍被其他path导入时保留
+func (bs *BaseSource) RemoveImport(path string) error {
+	bs.mutex.RLock()
+	store := bs.findStore(path)
+	keep := store == nil || len(store.opts.importChain) == 0
+	for _, s := range bs.stores {
+		keep = keep || hasImport(s.imports, importRef{source: bs.name, path: path})
+	}
+	bs.mutex.RUnlock()
+	if keep {
+		return nil
+	}
+	return bs.RemovePath(path)
+}
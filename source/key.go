@@ -0,0 +1,25 @@
+package source
+
+import (
+    "strings"
+)
+
+// KeyNormalizer 规范化key, 规范化后相同的key被认为是同一个配置
+type KeyNormalizer func(key string) string
+
+// RelaxedKey 宽松的key匹配: 忽略大小写, '-'和'_',
+// 如 db.maxConns, db.max-conns, DB.MAX_CONNS 都规范化为 db.maxconns
+func RelaxedKey(key string) string {
+    var b strings.Builder
+    b.Grow(len(key))
+    for _, c := range key {
+        switch {
+        case c == '-' || c == '_':
+        case c >= 'A' && c <= 'Z':
+            b.WriteRune(c + 'a' - 'A')
+        default:
+            b.WriteRune(c)
+        }
+    }
+    return b.String()
+}
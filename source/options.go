@@ -3,9 +3,11 @@ package source
 import "github.com/derry6/vade-go/source/parser"
 
 type options struct {
-    priority    int
-    prefix      string
-    withDeleted bool
+    priority       int
+    prefix         string
+    withDeleted    bool
+    importDisabled bool
+    normalize      KeyNormalizer
 }
 
 type Option func(opts *options)
@@ -28,6 +30,20 @@ func WithDeleted() Option {
     }
 }
 
+// WithImportDisabled 忽略配置中声明的导入(vade.import)
+func WithImportDisabled() Option {
+    return func(opts *options) {
+        opts.importDisabled = true
+    }
+}
+
+// WithKeyNormalizer 规范化配置的key, 查询时key也会被规范化
+func WithKeyNormalizer(fn KeyNormalizer) Option {
+    return func(opts *options) {
+        opts.normalize = fn
+    }
+}
+
 func newOptions(opts ...Option) *options {
     sOpts := &options{prefix: ""}
     for _, o := range opts {
@@ -41,6 +57,7 @@ type pathOptions struct {
     required      bool
     parser        parser.Parser
     watchDisabled bool
+    importChain   []string // 导入链, 用于检测循环导入
 }
 
 type PathOption func(opts *pathOptions)
@@ -69,6 +86,12 @@ func WithPathWatchDisabled() PathOption {
     }
 }
 
+func withImportChain(chain []string) PathOption {
+    return func(opts *pathOptions) {
+        opts.importChain = chain
+    }
+}
+
 func newPathOptions(opts ...PathOption) *pathOptions {
     nsOpts := &pathOptions{
         priority:      0,
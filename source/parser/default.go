@@ -1,5 +1,8 @@
 package parser
 
+import "bytes"
+
+// defaultParser 无法根据扩展名选择parser时使用, 自动检测配置的格式
 type defaultParser struct {
     yaml Parser
     json Parser
@@ -7,6 +10,12 @@ type defaultParser struct {
 }
 
 func (p *defaultParser) Parse(data []byte, prefix string) (values map[string]interface{}, err error) {
+    // 以 { 开头的内容优先作为json解析
+    if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+        if values, err = p.json.Parse(data, prefix); err == nil {
+            return
+        }
+    }
     if values, err = p.yaml.Parse(data, prefix); err != nil {
         if values, err = p.json.Parse(data, prefix); err != nil {
             return p.prop.Parse(data, prefix)
@@ -21,4 +30,4 @@ func NewDefault() Parser {
         json: NewJSON(),
         prop: NewProps(),
     }
-}
\ No newline at end of file
+}
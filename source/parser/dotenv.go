@@ -0,0 +1,215 @@
+package parser
+
+import (
+    "os"
+    "strings"
+
+    pkgerrs "github.com/pkg/errors"
+
+    "github.com/derry6/vade-go/pkg/envkey"
+)
+
+// dotenvParser 解析.env文件, 除了原始的变量名, 还会保存和env客户端相同的key, 如 FOO_BAR -> foo.bar
+type dotenvParser struct {
+    mapper *envkey.Mapper
+}
+
+func (p *dotenvParser) Parse(data []byte, prefix string) (values map[string]interface{}, err error) {
+    vars, err := parseDotenv(string(data))
+    if err != nil {
+        return nil, err
+    }
+    if n := len(prefix); n > 0 && prefix[n-1] != '.' {
+        prefix += "."
+    }
+    values = make(map[string]interface{})
+    for name, value := range vars {
+        for _, key := range p.mapper.KeysOf(name) {
+            values[prefix+key] = value
+        }
+    }
+    return values, nil
+}
+
+// NewDotenv 创建.env文件的parser
+func NewDotenv() Parser {
+    return &dotenvParser{}
+}
+
+// NewDotenvMapper 创建使用指定映射规则的.env文件parser
+func NewDotenvMapper(mapper *envkey.Mapper) Parser {
+    return &dotenvParser{mapper: mapper}
+}
+
+type dotenvReader struct {
+    lines  []string
+    lineNo int
+}
+
+func (r *dotenvReader) next() (line string, ok bool) {
+    if r.lineNo >= len(r.lines) {
+        return "", false
+    }
+    line = r.lines[r.lineNo]
+    r.lineNo++
+    return line, true
+}
+
+func (r *dotenvReader) errorf(format string, args ...interface{}) error {
+    return pkgerrs.Errorf("dotenv: line %d: "+format, append([]interface{}{r.lineNo}, args...)...)
+}
+
+func parseDotenv(data string) (vars map[string]string, err error) {
+    data = strings.Replace(data, "\r\n", "\n", -1)
+    r := &dotenvReader{lines: strings.Split(data, "\n")}
+    vars = map[string]string{}
+    for {
+        line, ok := r.next()
+        if !ok {
+            return vars, nil
+        }
+        line = strings.TrimSpace(line)
+        if line == "" || line[0] == '#' {
+            continue
+        }
+        if strings.HasPrefix(line, "export ") || strings.HasPrefix(line, "export\t") {
+            line = strings.TrimSpace(line[len("export"):])
+        }
+        i := strings.Index(line, "=")
+        if i <= 0 {
+            return nil, r.errorf("missing '=' in %q", line)
+        }
+        name := strings.TrimSpace(line[:i])
+        if strings.ContainsAny(name, " \t") {
+            return nil, r.errorf("invalid variable name %q", name)
+        }
+        value, err := r.parseValue(strings.TrimLeft(line[i+1:], " \t"), vars)
+        if err != nil {
+            return nil, err
+        }
+        vars[name] = value
+    }
+}
+
+func (r *dotenvReader) parseValue(raw string, vars map[string]string) (string, error) {
+    if raw == "" {
+        return "", nil
+    }
+    quote := raw[0]
+    if quote != '"' && quote != '\'' {
+        // 没有引号时, 空白后的 # 为注释
+        for i := 1; i < len(raw); i++ {
+            if raw[i] == '#' && (raw[i-1] == ' ' || raw[i-1] == '\t') {
+                raw = raw[:i]
+                break
+            }
+        }
+        return expandDotenv(strings.TrimSpace(raw), false, vars), nil
+    }
+    // 引号中的值可以跨越多行
+    body := raw[1:]
+    for {
+        if end := closingQuote(body, quote); end >= 0 {
+            rest := strings.TrimSpace(body[end+1:])
+            if rest != "" && rest[0] != '#' {
+                return "", r.errorf("unexpected %q after quoted value", rest)
+            }
+            body = body[:end]
+            break
+        }
+        line, ok := r.next()
+        if !ok {
+            return "", r.errorf("unterminated quoted value")
+        }
+        body += "\n" + line
+    }
+    if quote == '\'' {
+        return body, nil
+    }
+    return expandDotenv(body, true, vars), nil
+}
+
+func closingQuote(s string, quote byte) int {
+    for i := 0; i < len(s); i++ {
+        if quote == '"' && s[i] == '\\' {
+            i++
+            continue
+        }
+        if s[i] == quote {
+            return i
+        }
+    }
+    return -1
+}
+
+func lookupDotenv(name string, vars map[string]string) (string, bool) {
+    if v, ok := vars[name]; ok {
+        return v, true
+    }
+    return os.LookupEnv(name)
+}
+
+func isEnvNameChar(c byte) bool {
+    return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// expandDotenv 处理转义字符和 ${VAR}, $VAR 引用。
+// 引用的变量在文件和环境变量中都不存在时保持原样, 留给配置的变量替换处理。
+func expandDotenv(s string, escape bool, vars map[string]string) string {
+    b := strings.Builder{}
+    for i := 0; i < len(s); i++ {
+        c := s[i]
+        if escape && c == '\\' && i+1 < len(s) {
+            i++
+            switch s[i] {
+            case 'n':
+                b.WriteByte('\n')
+            case 'r':
+                b.WriteByte('\r')
+            case 't':
+                b.WriteByte('\t')
+            default:
+                b.WriteByte(s[i])
+            }
+            continue
+        }
+        if c != '$' || i+1 >= len(s) {
+            b.WriteByte(c)
+            continue
+        }
+        var name, def, ref string
+        hasDef := false
+        if s[i+1] == '{' {
+            end := strings.IndexByte(s[i:], '}')
+            if end < 0 {
+                b.WriteByte(c)
+                continue
+            }
+            ref = s[i : i+end+1]
+            name = ref[2 : len(ref)-1]
+            if j := strings.Index(name, ":-"); j >= 0 {
+                name, def, hasDef = name[:j], name[j+2:], true
+            }
+        } else {
+            j := i + 1
+            for j < len(s) && isEnvNameChar(s[j]) {
+                j++
+            }
+            if j == i+1 {
+                b.WriteByte(c)
+                continue
+            }
+            ref = s[i:j]
+            name = ref[1:]
+        }
+        if v, ok := lookupDotenv(name, vars); ok && (v != "" || !hasDef) {
+            b.WriteString(v)
+        } else if hasDef {
+            b.WriteString(def)
+        } else {
+            b.WriteString(ref)
+        }
+        i += len(ref) - 1
+    }
+    return b.String()
+}
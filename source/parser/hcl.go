@@ -0,0 +1,25 @@
+package parser
+
+import (
+    "github.com/hashicorp/hcl"
+)
+
+// hclParser 解析HCL(v1), 同名的block会合并, 如:
+//   server "a" { port = 80 }
+//   server "b" { port = 81 }
+// 解析为 server.a.port 和 server.b.port
+type hclParser struct {
+    opts options
+}
+
+func (p *hclParser) Parse(data []byte, prefix string) (values map[string]interface{}, err error) {
+    raw := make(map[string]interface{})
+    if err = hcl.Unmarshal(data, &raw); err != nil {
+        return nil, err
+    }
+    values = make(map[string]interface{})
+    err = p.opts.flatten(normalize(raw, true).(map[string]interface{}), values, prefix)
+    return
+}
+
+func NewHCL(opts ...Option) Parser { return &hclParser{opts: newOptions(opts)} }
@@ -0,0 +1,32 @@
+package parser
+
+import (
+    "gopkg.in/ini.v1"
+)
+
+// iniParser 解析INI, section中的key为 section.key, 默认section中的key没有前缀
+type iniParser struct {
+}
+
+func (p *iniParser) Parse(data []byte, prefix string) (values map[string]interface{}, err error) {
+    f, err := ini.Load(data)
+    if err != nil {
+        return nil, err
+    }
+    if n := len(prefix); n > 0 && prefix[n-1] != '.' {
+        prefix += "."
+    }
+    values = make(map[string]interface{})
+    for _, section := range f.Sections() {
+        sectionPrefix := prefix
+        if name := section.Name(); name != ini.DEFAULT_SECTION {
+            sectionPrefix += name + "."
+        }
+        for _, key := range section.Keys() {
+            values[sectionPrefix+key.Name()] = key.Value()
+        }
+    }
+    return values, nil
+}
+
+func NewINI() Parser { return &iniParser{} }
@@ -2,11 +2,10 @@ package parser
 
 import (
     "encoding/json"
-
-    "github.com/derry6/vade-go/pkg/flatter"
 )
 
 type jsonParser struct {
+    opts options
 }
 
 func (p *jsonParser) Parse(data []byte, prefix string) (values map[string]interface{}, err error) {
@@ -15,8 +14,8 @@ func (p *jsonParser) Parse(data []byte, prefix string) (values map[string]interf
         return
     }
     values = make(map[string]interface{})
-    err = flatter.Flatten(raw, values, prefix, false)
+    err = p.opts.flatten(raw, values, prefix)
     return
 }
 
-func NewJSON() Parser { return &jsonParser{} }
\ No newline at end of file
+func NewJSON(opts ...Option) Parser { return &jsonParser{opts: newOptions(opts)} }
\ No newline at end of file
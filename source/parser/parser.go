@@ -1,6 +1,39 @@
 package parser
 
+import (
+    "github.com/derry6/vade-go/pkg/flatter"
+)
+
 // Parser properties parser
 type Parser interface {
     Parse(data []byte, prefix string) (props map[string]interface{}, err error)
 }
+
+// Option yaml, json, toml和hcl parser的选项
+type Option func(opts *options)
+
+type options struct {
+    keepEmpty bool
+}
+
+// WithEmpty 保留空的map和列表, 空map的值为map[string]interface{}{}, 空列表的值为[]interface{}{}, 用于Unmarshal区分空和不存在,
+// 默认忽略空的map, 如:
+//   parser.Register("yaml", parser.NewYAML(parser.WithEmpty()))
+func WithEmpty() Option {
+    return func(opts *options) { opts.keepEmpty = true }
+}
+
+func newOptions(opts []Option) options {
+    o := options{}
+    for _, opt := range opts {
+        opt(&o)
+    }
+    return o
+}
+
+func (o options) flatten(src, dst map[string]interface{}, prefix string) error {
+    if o.keepEmpty {
+        return flatter.FlattenWith(src, dst, prefix, flatter.WithEmpty())
+    }
+    return flatter.FlattenWith(src, dst, prefix)
+}
@@ -15,9 +15,9 @@ func TestParseData(t *testing.T) {
         data   string
         values v
     }{
-        {`invalid data`, v{}},
+        {`invalid data`, v{"invalid": "data"}}, // properties中空白可以作为分隔符
         {`a: 10 # from yaml`, v{"a": 10}},
-        {`b = 100 # from properties`, v{"b": "100"}},
+        {`b = 100 # from properties`, v{"b": "100 # from properties"}}, // properties不支持行尾注释
         {`{"c": "valuec"}`, v{"c": "valuec"}},
         {``, v{}},
     }
@@ -27,3 +27,146 @@ func TestParseData(t *testing.T) {
         assert.Equal(t, x, testCase.values, "values not match")
     }
 }
+
+func TestParseDotenv(t *testing.T) {
+    type v = map[string]interface{}
+    data := `
+# comment
+export FOO_BAR=bar # inline comment
+URL=jdbc:mysql://h/db?a=b#frag
+SINGLE='keep ${FOO_BAR} # not comment'
+DOUBLE="line1\nhas \"quote\" # not comment"
+MULTI="first
+second"
+REF=${FOO_BAR}-$FOO_BAR
+DEF=${VADE_DOTENV_NOT_EXISTS:-def}
+KEEP=${db.host}
+`
+    values, err := parser.NewDotenv().Parse([]byte(data), "")
+    assert.NoError(t, err)
+    assert.Equal(t, v{
+        "FOO_BAR": "bar", "foo.bar": "bar",
+        "URL": "jdbc:mysql://h/db?a=b#frag", "url": "jdbc:mysql://h/db?a=b#frag",
+        "SINGLE": "keep ${FOO_BAR} # not comment", "single": "keep ${FOO_BAR} # not comment",
+        "DOUBLE": "line1\nhas \"quote\" # not comment", "double": "line1\nhas \"quote\" # not comment",
+        "MULTI": "first\nsecond", "multi": "first\nsecond",
+        "REF": "bar-bar", "ref": "bar-bar",
+        "DEF": "def", "def": "def",
+        "KEEP": "${db.host}", "keep": "${db.host}",
+    }, values)
+
+    _, err = parser.NewDotenv().Parse([]byte(`A="unterminated`), "")
+    assert.Error(t, err)
+}
+
+func TestParseProps(t *testing.T) {
+    type v = map[string]interface{}
+    data := "# comment\n" +
+        "! comment too\n" +
+        "url=jdbc:mysql://h/db?a=b\n" +
+        "colon:value\n" +
+        "space value with spaces\n" +
+        "  indented  =  trimmed \n" +
+        "multi = first, \\\n" +
+        "        second\n" +
+        "key\\=with\\:seps = v\n" +
+        "unicode=\\u4e2d\\u6587\\uD83D\\uDE00\n" +
+        "escapes=a\\tb\\nc\\\\\n" +
+        "empty\n" +
+        "even=a\\\\\n" +
+        "next=line\r\n"
+    values, err := parser.NewProps().Parse([]byte(data), "")
+    assert.NoError(t, err)
+    assert.Equal(t, v{
+        "url":           "jdbc:mysql://h/db?a=b",
+        "colon":         "value",
+        "space":         "value with spaces",
+        "indented":      "trimmed ",
+        "multi":         "first, second",
+        "key=with:seps": "v",
+        "unicode":       "中文😀",
+        "escapes":       "a\tb\nc\\",
+        "empty":         "",
+        "even":          "a\\",
+        "next":          "line",
+    }, values)
+
+    _, err = parser.NewProps().Parse([]byte(`bad=\u12`), "")
+    assert.Error(t, err)
+}
+
+func TestPropsRoundTrip(t *testing.T) {
+    values := map[string]interface{}{
+        "a.b":          "jdbc:mysql://h/db?a=b&c=d",
+        "key with =:":  " leading space",
+        "#not.comment": "!value#",
+        "unicode":      "中文😀\t\n\f\r",
+        "backslash":    `C:\dir\`,
+        "number":       100,
+    }
+    data, err := parser.MarshalProps(values)
+    assert.NoError(t, err)
+    got, err := parser.NewProps().Parse(data, "")
+    assert.NoError(t, err)
+    values["number"] = "100"
+    assert.Equal(t, values, got)
+    // Java Properties.store的输出
+    assert.Contains(t, string(data), `key\ with\ \=\:=\ leading space`)
+    assert.Contains(t, string(data), `unicode=\u4E2D\u6587\uD83D\uDE00\t\n\f\r`)
+}
+
+func TestParseByExtension(t *testing.T) {
+    type v = map[string]interface{}
+    var testCases = []struct {
+        path   string
+        data   string
+        values v
+    }{
+        {"a.toml", "title = \"t\"\n[db]\nport = 3306\n[[servers]]\nname = \"s1\"\n", v{
+            "title": "t", "db.port": int64(3306), "servers": 1, "servers[0].name": "s1",
+        }},
+        {"a.ini", "name = app\n[db]\nport = 3306\n", v{"name": "app", "db.port": "3306"}},
+        {"a.hcl", "name = \"app\"\nserver \"a\" {\n  port = 80\n}\nserver \"b\" {\n  port = 81\n}\n", v{
+            "name": "app", "server.a.port": 80, "server.b.port": 81,
+        }},
+        {"conf/.env.local", "FOO=bar\n", v{"FOO": "bar", "foo": "bar"}},
+        {"a.yaml?group=x", "a: 1\n", v{"a": 1}},
+    }
+    for _, testCase := range testCases {
+        p := parser.ForPath(testCase.path)
+        if !assert.NotNil(t, p, testCase.path) {
+            continue
+        }
+        values, err := p.Parse([]byte(testCase.data), "")
+        assert.NoError(t, err, testCase.path)
+        assert.Equal(t, testCase.values, values, testCase.path)
+    }
+    assert.Nil(t, parser.ForPath("noext"))
+
+    parser.Register(".CUSTOM", parser.NewJSON())
+    _, ok := parser.Get("custom")
+    assert.True(t, ok)
+
+    // .env文件使用注册的env parser
+    parser.Register("env", parser.NewJSON())
+    defer parser.Register("env", parser.NewDotenv())
+    values, err := parser.ForPath(".env.local").Parse([]byte(`{"a":"b"}`), "")
+    assert.NoError(t, err)
+    assert.Equal(t, map[string]interface{}{"a": "b"}, values)
+}
+
+func TestParseEmpty(t *testing.T) {
+    type v = map[string]interface{}
+    data := []byte("a: 1\nb: {}\nc: []\n")
+    values, err := parser.NewYAML().Parse(data, "")
+    assert.NoError(t, err)
+    assert.Equal(t, v{"a": 1, "c": 0}, values)
+
+    values, err = parser.NewYAML(parser.WithEmpty()).Parse(data, "")
+    assert.NoError(t, err)
+    assert.Equal(t, v{"a": 1, "b": v{}, "c": []interface{}{}}, values)
+
+    values, err = parser.NewJSON(parser.WithEmpty()).Parse([]byte(`{"b": {}}`), "")
+    assert.NoError(t, err)
+    assert.Equal(t, v{"b": v{}}, values)
+}
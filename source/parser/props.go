@@ -1,48 +1,228 @@
 package parser
 
 import (
-    "bufio"
     "bytes"
-    "io"
+    "fmt"
+    "sort"
+    "strconv"
     "strings"
+    "unicode/utf16"
+
+    pkgerrs "github.com/pkg/errors"
+    "github.com/spf13/cast"
 )
 
+// propsParser 按照Java Properties的格式解析:
+// https://docs.oracle.com/javase/8/docs/api/java/util/Properties.html#load-java.io.Reader-
 type propsParser struct {
 }
 
 func (p *propsParser) Parse(data []byte, prefix string) (values map[string]interface{}, err error) {
-    var line string
     values = make(map[string]interface{})
-    buf := bufio.NewReader(bytes.NewReader(data))
     if n := len(prefix); n > 0 && prefix[n-1] != '.' {
         prefix += "."
     }
-    for {
-        if err == io.EOF {
+    for _, line := range logicalLines(string(data)) {
+        key, value, err := parsePropsLine(line)
+        if err != nil {
+            return nil, err
+        }
+        if len(key) == 0 {
+            continue
+        }
+        values[prefix+key] = value
+    }
+    return values, nil
+}
+
+func NewProps() Parser {
+    return &propsParser{}
+}
+
+func isPropsSpace(c byte) bool {
+    return c == ' ' || c == '\t' || c == '\f'
+}
+
+// logicalLines 将文本拆分为逻辑行, 去掉空行和注释行,
+// 以奇数个 \ 结尾的行与下一行合并, 下一行开头的空白被忽略。
+func logicalLines(data string) (lines []string) {
+    data = strings.Replace(data, "\r\n", "\n", -1)
+    data = strings.Replace(data, "\r", "\n", -1)
+    var (
+        buf        strings.Builder
+        continuing bool
+    )
+    for _, natural := range strings.Split(data, "\n") {
+        natural = strings.TrimLeft(natural, " \t\f")
+        if !continuing {
+            if natural == "" || natural[0] == '#' || natural[0] == '!' {
+                continue
+            }
+        }
+        backslashes := 0
+        for i := len(natural) - 1; i >= 0 && natural[i] == '\\'; i-- {
+            backslashes++
+        }
+        continuing = backslashes%2 == 1
+        if continuing {
+            natural = natural[:len(natural)-1]
+        }
+        buf.WriteString(natural)
+        if !continuing {
+            lines = append(lines, buf.String())
+            buf.Reset()
+        }
+    }
+    if buf.Len() > 0 {
+        lines = append(lines, buf.String())
+    }
+    return lines
+}
+
+// parsePropsLine 解析逻辑行中的key和value,
+// key以第一个未转义的 =, : 或者空白结束。
+func parsePropsLine(line string) (key, value string, err error) {
+    keyLen, valueStart := 0, len(line)
+    hasSep, escaped := false, false
+    for keyLen < len(line) {
+        c := line[keyLen]
+        if (c == '=' || c == ':') && !escaped {
+            valueStart = keyLen + 1
+            hasSep = true
             break
         }
-        line, err = buf.ReadString('\n')
-        if err != nil && err != io.EOF {
-            return nil, err
+        if isPropsSpace(c) && !escaped {
+            valueStart = keyLen + 1
+            break
+        }
+        escaped = c == '\\' && !escaped
+        keyLen++
+    }
+    for valueStart < len(line) {
+        c := line[valueStart]
+        if !isPropsSpace(c) {
+            if !hasSep && (c == '=' || c == ':') {
+                hasSep = true
+            } else {
+                break
+            }
+        }
+        valueStart++
+    }
+    if key, err = unescapeProps(line[:keyLen]); err != nil {
+        return
+    }
+    if valueStart < len(line) {
+        value, err = unescapeProps(line[valueStart:])
+    }
+    return key, value, err
+}
+
+func unescapeProps(s string) (string, error) {
+    if strings.IndexByte(s, '\\') < 0 {
+        return s, nil
+    }
+    var (
+        b     strings.Builder
+        units []uint16
+    )
+    // \uXXXX 是UTF-16编码, 需要处理代理对
+    flush := func() {
+        if len(units) > 0 {
+            b.WriteString(string(utf16.Decode(units)))
+            units = units[:0]
         }
-        parts := strings.Split(line, "=")
-        if len(parts) != 2 {
+    }
+    for i := 0; i < len(s); i++ {
+        c := s[i]
+        if c != '\\' || i+1 >= len(s) {
+            flush()
+            if c != '\\' {
+                b.WriteByte(c)
+            }
             continue
         }
-        key := strings.TrimSpace(parts[0])
-        if len(key) == 0 || key[0] == '#' {
+        i++
+        if s[i] == 'u' {
+            if i+4 >= len(s) {
+                return "", pkgerrs.Errorf("malformed \\uxxxx encoding: %q", s)
+            }
+            u, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+            if err != nil {
+                return "", pkgerrs.Errorf("malformed \\uxxxx encoding: %q", s)
+            }
+            units = append(units, uint16(u))
+            i += 4
             continue
         }
-        key = prefix + key
-        i := strings.Index(parts[1], "#")
-        if i > 0 {
-            parts[1] = parts[1][:i]
+        flush()
+        switch s[i] {
+        case 't':
+            b.WriteByte('\t')
+        case 'n':
+            b.WriteByte('\n')
+        case 'r':
+            b.WriteByte('\r')
+        case 'f':
+            b.WriteByte('\f')
+        default:
+            b.WriteByte(s[i])
         }
-        values[key] = strings.TrimSpace(parts[1])
     }
-    return values, nil
+    flush()
+    return b.String(), nil
 }
 
-func NewProps() Parser {
-    return &propsParser{}
-}
\ No newline at end of file
+func escapeProps(s string, isKey bool) string {
+    var b strings.Builder
+    for i, r := range s {
+        switch r {
+        case ' ':
+            if isKey || i == 0 {
+                b.WriteByte('\\')
+            }
+            b.WriteByte(' ')
+        case '\t':
+            b.WriteString(`\t`)
+        case '\n':
+            b.WriteString(`\n`)
+        case '\r':
+            b.WriteString(`\r`)
+        case '\f':
+            b.WriteString(`\f`)
+        case '\\', '=', ':', '#', '!':
+            b.WriteByte('\\')
+            b.WriteRune(r)
+        default:
+            if r < 0x20 || r > 0x7e {
+                for _, u := range utf16.Encode([]rune{r}) {
+                    fmt.Fprintf(&b, "\\u%04X", u)
+                }
+            } else {
+                b.WriteRune(r)
+            }
+        }
+    }
+    return b.String()
+}
+
+// MarshalProps 按照Java Properties.store的格式输出, key按照字母排序
+func MarshalProps(values map[string]interface{}) ([]byte, error) {
+    keys := make([]string, 0, len(values))
+    for k := range values {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    buf := bytes.Buffer{}
+    for _, k := range keys {
+        v, err := cast.ToStringE(values[k])
+        if err != nil {
+            return nil, pkgerrs.Wrapf(err, "value of key %q", k)
+        }
+        buf.WriteString(escapeProps(k, true))
+        buf.WriteByte('=')
+        buf.WriteString(escapeProps(v, false))
+        buf.WriteByte('\n')
+    }
+    return buf.Bytes(), nil
+}
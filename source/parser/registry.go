@@ -0,0 +1,64 @@
+package parser
+
+import (
+    "path/filepath"
+    "strings"
+    "sync"
+)
+
+var (
+    registry   = map[string]Parser{}
+    registryMu sync.RWMutex
+)
+
+func init() {
+    Register("yaml", NewYAML())
+    Register("yml", NewYAML())
+    Register("json", NewJSON())
+    Register("properties", NewProps())
+    Register("props", NewProps())
+    Register("env", NewDotenv())
+    Register("toml", NewTOML())
+    Register("ini", NewINI())
+    Register("hcl", NewHCL())
+    Register("tf", NewHCL())
+}
+
+func normalizeExt(ext string) string {
+    return strings.ToLower(strings.TrimPrefix(ext, "."))
+}
+
+// Register 注册扩展名对应的parser, 扩展名不区分大小写
+func Register(ext string, p Parser) {
+    registryMu.Lock()
+    defer registryMu.Unlock()
+    if p == nil {
+        delete(registry, normalizeExt(ext))
+        return
+    }
+    registry[normalizeExt(ext)] = p
+}
+
+// Get 返回扩展名对应的parser
+func Get(ext string) (p Parser, ok bool) {
+    registryMu.RLock()
+    defer registryMu.RUnlock()
+    p, ok = registry[normalizeExt(ext)]
+    return
+}
+
+// ForPath 根据path的文件名选择parser, 没有匹配的parser时返回nil
+func ForPath(path string) Parser {
+    if i := strings.Index(path, "?"); i >= 0 {
+        path = path[:i]
+    }
+    base := filepath.Base(path)
+    ext := filepath.Ext(base)
+    if base == ".env" || strings.HasPrefix(base, ".env.") {
+        ext = "env"
+    }
+    if p, ok := Get(ext); ok {
+        return p
+    }
+    return nil
+}
@@ -0,0 +1,21 @@
+package parser
+
+import (
+    "github.com/BurntSushi/toml"
+)
+
+type tomlParser struct {
+    opts options
+}
+
+func (p *tomlParser) Parse(data []byte, prefix string) (values map[string]interface{}, err error) {
+    raw := make(map[string]interface{})
+    if _, err = toml.Decode(string(data), &raw); err != nil {
+        return nil, err
+    }
+    values = make(map[string]interface{})
+    err = p.opts.flatten(normalize(raw, false).(map[string]interface{}), values, prefix)
+    return
+}
+
+func NewTOML(opts ...Option) Parser { return &tomlParser{opts: newOptions(opts)} }
@@ -0,0 +1,46 @@
+package parser
+
+// normalize 将解析结果转为flatter支持的类型,
+// mergeMaps为true时, map的列表合并为一个map。
+func normalize(v interface{}, mergeMaps bool) interface{} {
+    switch x := v.(type) {
+    case map[string]interface{}:
+        for k, item := range x {
+            x[k] = normalize(item, mergeMaps)
+        }
+        return x
+    case []map[string]interface{}:
+        if mergeMaps {
+            merged := map[string]interface{}{}
+            for _, m := range x {
+                for k, item := range m {
+                    merged[k] = mergeValue(merged[k], normalize(item, mergeMaps))
+                }
+            }
+            return merged
+        }
+        items := make([]interface{}, len(x))
+        for i, m := range x {
+            items[i] = normalize(m, mergeMaps)
+        }
+        return items
+    case []interface{}:
+        for i, item := range x {
+            x[i] = normalize(item, mergeMaps)
+        }
+        return x
+    }
+    return v
+}
+
+func mergeValue(dst, src interface{}) interface{} {
+    d, ok1 := dst.(map[string]interface{})
+    s, ok2 := src.(map[string]interface{})
+    if !ok1 || !ok2 {
+        return src
+    }
+    for k, v := range s {
+        d[k] = mergeValue(d[k], v)
+    }
+    return d
+}
@@ -5,11 +5,10 @@ import (
 	"io"
 
 	"gopkg.in/yaml.v2"
-
-	"github.com/derry6/vade-go/pkg/flatter"
 )
 
 type yamlParser struct {
+	opts options
 }
 
 func (p *yamlParser) Parse(data []byte, prefix string) (values map[string]interface{}, err error) {
@@ -26,12 +25,12 @@ func (p *yamlParser) Parse(data []byte, prefix string) (values map[string]interf
 			}
 			return nil, err
 		}
-		if err = flatter.Flatten(raw, values, prefix, false); err != nil {
+		if err = p.opts.flatten(raw, values, prefix); err != nil {
 			return nil, err
 		}
 	}
 }
 
-func NewYAML() Parser {
-	return &yamlParser{}
+func NewYAML(opts ...Option) Parser {
+	return &yamlParser{opts: newOptions(opts)}
 }
@@ -12,10 +12,14 @@ type configValue struct {
 }
 
 type pathStore struct {
-	path   string
-	pri    int
-	parser parser.Parser
-	values map[string]interface{}
+	path    string
+	pri     int
+	parser  parser.Parser // 指定的parser, 为空时根据format选择
+	format  string        // 客户端返回的格式
+	version string        // 客户端返回的版本
+	values  map[string]interface{}
+	opts    *pathOptions
+	imports []importRef // 配置中声明的导入
 }
 
 type pathHighToLow []*pathStore
@@ -105,13 +105,13 @@ func TestConfigPathUpdate(t *testing.T) {
 
 func TestConfigPathSort(t *testing.T) {
     namespaces := []*pathStore{
-        { "n1", 1,nil, nil },
-        { "n2", 5,nil, nil },
-        { "n3", 9,nil, nil },
-        { "n4", 9,nil, nil },
-        { "n5", 4,nil, nil },
-        { "n6", 2,nil, nil },
-        { "n7", 0,nil, nil },
+        {path: "n1", pri: 1},
+        {path: "n2", pri: 5},
+        {path: "n3", pri: 9},
+        {path: "n4", pri: 9},
+        {path: "n5", pri: 4},
+        {path: "n6", pri: 2},
+        {path: "n7", pri: 0},
     }
     sort.Sort(pathHighToLow(namespaces))
     last := math.MaxInt32
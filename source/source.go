@@ -31,6 +31,12 @@ type Source interface {
     OnEvents(cb func([]*Event))
 }
 
+// PathRemover 可以删除配置集合的Source
+type PathRemover interface {
+    // 删除配置集合, 该path中的配置会产生删除事件
+    RemovePath(path string) (err error)
+}
+
 func buildRandomName() string {
     return fmt.Sprintf("source-%d", rand.Int())
 }
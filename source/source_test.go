@@ -3,11 +3,17 @@ package source
 import (
     "context"
     "errors"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "path/filepath"
     "sync"
     "testing"
+    "time"
 
     "github.com/stretchr/testify/assert"
 
+    "github.com/derry6/vade-go/pkg/log"
     "github.com/derry6/vade-go/source/client"
 )
 
@@ -114,3 +120,160 @@ func TestSourceAddPathPriority(t *testing.T) {
         assert.Equal(t, p.values["a"], v)
     }
 }
+
+func TestSourceImport(t *testing.T) {
+    s := newFakeSource(t)
+    _ = s.Client().Push(context.TODO(), "conf/main.yaml", []byte("vade:\n  import: [common.yaml]\na: main\n"))
+    _ = s.Client().Push(context.TODO(), "conf/common.yaml", []byte("a: common\nb: common\n"))
+    err := s.AddPath("conf/main.yaml", WithPathRequired())
+    assert.NoError(t, err)
+    v, _ := s.Get("a")
+    assert.Equal(t, "main", v)
+    v, _ = s.Get("b")
+    assert.Equal(t, "common", v)
+
+    // 删除导入后, 导入的path也被删除
+    _ = s.Client().Push(context.TODO(), "conf/main.yaml", []byte("a: main\n"))
+    assert.NoError(t, s.(*BaseSource).Reload("conf/main.yaml"))
+    _, ok := s.Get("b")
+    assert.False(t, ok)
+    assert.Equal(t, []string{"conf/main.yaml"}, s.(*BaseSource).Paths())
+
+    // 跨source导入, 设置回调前声明的导入在设置时执行
+    var imported []string
+    _ = s.Client().Push(context.TODO(), "other.yaml", []byte("vade.import: \"nacos:shared.yaml\"\n"))
+    assert.NoError(t, s.AddPath("other.yaml"))
+    s.(*BaseSource).OnImport(func(source string, path string, opts ...PathOption) error {
+        imported = append(imported, source+":"+path)
+        return nil
+    }, func(source string, path string) error {
+        imported = append(imported, "-"+source+":"+path)
+        return nil
+    })
+    assert.Equal(t, []string{"nacos:shared.yaml"}, imported)
+    assert.NoError(t, s.(PathRemover).RemovePath("other.yaml"))
+    assert.Equal(t, []string{"nacos:shared.yaml", "-nacos:shared.yaml"}, imported)
+}
+
+func TestSourceImportCycle(t *testing.T) {
+    s := newFakeSource(t)
+    _ = s.Client().Push(context.TODO(), "x.yaml", []byte("vade.import: y.yaml\na: x\n"))
+    _ = s.Client().Push(context.TODO(), "y.yaml", []byte("vade.import: x.yaml\n"))
+    err := s.AddPath("x.yaml", WithPathRequired())
+    assert.Error(t, err)
+    // 导入失败时不保留导入者
+    assert.Empty(t, s.(*BaseSource).Paths())
+    _, ok := s.Get("a")
+    assert.False(t, ok)
+}
+
+func TestSourceRemovePath(t *testing.T) {
+    s := newFakeSource(t)
+    _ = s.Client().Push(context.TODO(), "p0", []byte("a: p0\nb: p0\n"))
+    _ = s.Client().Push(context.TODO(), "p1", []byte("a: p1\n"))
+    assert.NoError(t, s.AddPath("p0"))
+    assert.NoError(t, s.AddPath("p1", WithPathPriority(1)))
+    v, _ := s.Get("a")
+    assert.Equal(t, "p1", v)
+
+    assert.NoError(t, s.(PathRemover).RemovePath("p1"))
+    v, _ = s.Get("a")
+    assert.Equal(t, "p0", v)
+    assert.NoError(t, s.(PathRemover).RemovePath("p0"))
+    _, ok := s.Get("b")
+    assert.False(t, ok)
+}
+
+// recordLogger 记录警告和错误日志
+type recordLogger struct {
+    mu   sync.Mutex
+    logs []string
+}
+
+func (l *recordLogger) record(format string, args ...interface{}) {
+    l.mu.Lock()
+    l.logs = append(l.logs, fmt.Sprintf(format, args...))
+    l.mu.Unlock()
+}
+func (l *recordLogger) Debugf(format string, args ...interface{}) {}
+func (l *recordLogger) Infof(format string, args ...interface{})  {}
+func (l *recordLogger) Warnf(format string, args ...interface{})  { l.record(format, args...) }
+func (l *recordLogger) Errorf(format string, args ...interface{}) { l.record(format, args...) }
+func (l *recordLogger) Fatalf(format string, args ...interface{}) { l.record(format, args...) }
+
+func TestSourceRemovePathUnwatch(t *testing.T) {
+    dir, err := ioutil.TempDir("", "vade-source")
+    assert.NoError(t, err)
+    defer os.RemoveAll(dir)
+    file := filepath.Join(dir, "app.yaml")
+    assert.NoError(t, ioutil.WriteFile(file, []byte("a: 1\n"), 0644))
+
+    logger := &recordLogger{}
+    defer log.Use(log.Get())
+    log.Use(logger)
+
+    c, err := client.New(client.File, client.DefaultConfig())
+    assert.NoError(t, err)
+    defer c.Close()
+    s := New("file", c)
+    events := make(chan []*Event, 10)
+    s.OnEvents(func(evs []*Event) { events <- evs })
+    assert.NoError(t, s.AddPath(file))
+    assert.NoError(t, s.(PathRemover).RemovePath(file))
+    // 添加和删除path的事件异步派发
+    <-events
+    <-events
+
+    // 删除后文件的变化不再处理
+    assert.NoError(t, ioutil.WriteFile(file, []byte("a: 2\n"), 0644))
+    select {
+    case evs := <-events:
+        t.Fatalf("unexpected events after remove: %v", evs)
+    case <-time.After(300 * time.Millisecond):
+    }
+    logger.mu.Lock()
+    defer logger.mu.Unlock()
+    assert.Empty(t, logger.logs)
+}
+
+type fakeResponseClient struct {
+    fakeClient
+    formats map[string]string
+    cbs     map[string]client.ResponseCallback
+}
+
+func (c *fakeResponseClient) PullResponse(ctx context.Context, path string) (*client.Response, error) {
+    data, err := c.Pull(ctx, path)
+    if err != nil {
+        return nil, err
+    }
+    return &client.Response{Data: data, Format: c.formats[path], Version: "1"}, nil
+}
+func (c *fakeResponseClient) WatchResponse(path string, cb client.ResponseCallback) error {
+    c.cbs[path] = cb
+    return nil
+}
+
+func TestSourceResponseFormat(t *testing.T) {
+    c := &fakeResponseClient{
+        fakeClient: fakeClient{data: map[string]string{}},
+        formats:    map[string]string{"app": "toml"},
+        cbs:        map[string]client.ResponseCallback{},
+    }
+    s := New("fake", c)
+    _ = c.Push(context.TODO(), "app", []byte("a = \"x\"\n[b]\nc = 2\n"))
+    assert.NoError(t, s.AddPath("app", WithPathRequired()))
+    v, _ := s.Get("b.c")
+    assert.EqualValues(t, 2, v)
+    version, format, ok := s.(*BaseSource).PathVersion("app")
+    assert.True(t, ok)
+    assert.Equal(t, "1", version)
+    assert.Equal(t, "toml", format)
+
+    c.cbs["app"](&client.Response{Data: []byte(`{"b": {"c": 3}}`), Format: "json", Version: "2"})
+    v, _ = s.Get("b.c")
+    assert.EqualValues(t, 3, v)
+    version, format, _ = s.(*BaseSource).PathVersion("app")
+    assert.Equal(t, "2", version)
+    assert.Equal(t, "json", format)
+}
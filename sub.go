@@ -0,0 +1,198 @@
+package vade
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/derry6/vade-go/source"
+)
+
+// subManager prefix下配置的视图, 读写时自动添加prefix
+type subManager struct {
+	parent    Manager
+	prefix    string // 以.结尾
+	normalize source.KeyNormalizer
+}
+
+func newSubManager(parent Manager, prefix string) Manager {
+	prefix = strings.Trim(prefix, ".")
+	if prefix == "" {
+		return parent
+	}
+	s := &subManager{parent: parent, prefix: prefix + "."}
+	if mgr, ok := parent.(*manager); ok && mgr.normalize != nil {
+		s.normalize = mgr.normalize
+		s.prefix = mgr.normalize(s.prefix)
+	}
+	return s
+}
+
+func (s *subManager) fullKey(key string) string { return s.prefix + key }
+
+func (s *subManager) relKey(key string) (string, bool) {
+	if !strings.HasPrefix(key, s.prefix) {
+		return "", false
+	}
+	return key[len(s.prefix):], true
+}
+
+func (s *subManager) AddSource(src source.Source) error { return s.parent.AddSource(src) }
+
+func (s *subManager) Source(name string) (source.Source, error) { return s.parent.Source(name) }
+
+func (s *subManager) Sources() []source.Source { return s.parent.Sources() }
+
+func (s *subManager) AddPath(sourceName string, path string, opts ...source.PathOption) error {
+	return s.parent.AddPath(sourceName, path, opts...)
+}
+
+func (s *subManager) All() (values map[string]interface{}) {
+	values = map[string]interface{}{}
+	for k, v := range s.parent.All() {
+		if rel, ok := s.relKey(k); ok {
+			values[rel] = v
+		}
+	}
+	return values
+}
+
+func (s *subManager) Keys() (keys []string) {
+	for _, k := range s.parent.Keys() {
+		if rel, ok := s.relKey(k); ok {
+			keys = append(keys, rel)
+		}
+	}
+	return keys
+}
+
+func (s *subManager) Get(key string) (value interface{}, ok bool) {
+	return s.parent.Get(s.fullKey(key))
+}
+
+func (s *subManager) Set(key string, value interface{}) {
+	s.parent.Set(s.fullKey(key), value)
+}
+
+func (s *subManager) SetDefault(key string, value interface{}) {
+	s.parent.SetDefault(s.fullKey(key), value)
+}
+
+func (s *subManager) Delete(key string) {
+	s.parent.Delete(s.fullKey(key))
+}
+
+func (s *subManager) DeleteOverride(key string) {
+	s.parent.DeleteOverride(s.fullKey(key))
+}
+
+func (s *subManager) Watch(pattern string, handler EventHandler) (watchId int64) {
+	h := &subHandler{sub: s, handler: handler}
+	if re, err := regexp.Compile(normalizePattern(pattern, s.normalize)); err == nil {
+		h.pattern = re
+	}
+	return s.parent.Watch("^"+regexp.QuoteMeta(s.prefix), h)
+}
+
+func (s *subManager) Unwatch(id int64) {
+	s.parent.Unwatch(id)
+}
+
+func (s *subManager) Watchers() []Watcher { return s.parent.Watchers() }
+
+func (s *subManager) Unmarshal(out interface{}, opts ...UnmarshalOption) error {
+	opts = append([]UnmarshalOption{withUnmarshalOrigin(s.Origin), withUnmarshalNormalizer(s.normalize)}, opts...)
+	if mgr, ok := s.parent.(*manager); ok && s.normalize != nil {
+		n := strings.Count(s.prefix, ".")
+		opts = append([]UnmarshalOption{withUnmarshalOriginalKey(func(key string) string {
+			// 去掉prefix对应的部分
+			if parts := strings.SplitN(mgr.originalKey(s.fullKey(key)), ".", n+1); len(parts) == n+1 {
+				return parts[n]
+			}
+			return key
+		})}, opts...)
+	}
+	return unmarshal(s.Get, s.Keys(), out, opts...)
+}
+
+func (s *subManager) Origin(key string) (sourceName string, path string, ok bool) {
+	return s.parent.Origin(s.fullKey(key))
+}
+
+func (s *subManager) RegisterAlias(oldKey string, newKey string) {
+	s.parent.RegisterAlias(s.fullKey(oldKey), s.fullKey(newKey))
+}
+
+func (s *subManager) Deprecate(key string, message string, replacement string) {
+	if replacement != "" {
+		replacement = s.fullKey(replacement)
+	}
+	s.parent.Deprecate(s.fullKey(key), message, replacement)
+}
+
+func (s *subManager) DeprecatedKeys() []DeprecatedKey { return s.parent.DeprecatedKeys() }
+
+func (s *subManager) History() []*ChangeSet { return s.parent.History() }
+
+func (s *subManager) Diff(from int64, to int64) ([]*Change, error) { return s.parent.Diff(from, to) }
+
+func (s *subManager) Rollback(version int64) error { return s.parent.Rollback(version) }
+
+func (s *subManager) ClearRollback() { s.parent.ClearRollback() }
+
+func (s *subManager) Degraded() []string { return s.parent.Degraded() }
+
+// Close 子视图不拥有manager, 不做任何操作
+func (s *subManager) Close() error { return nil }
+
+func (s *subManager) Sub(prefix string) Manager {
+	return newSubManager(s.parent, s.prefix+strings.Trim(prefix, "."))
+}
+
+func (s *subManager) BindEnv(prefix string, v interface{}) error {
+	return s.parent.BindEnv(s.fullKey(strings.Trim(prefix, ".")), v)
+}
+
+func (s *subManager) EnvBindings() []EnvBinding { return s.parent.EnvBindings() }
+
+func (s *subManager) IntProperty(key string, def int) *IntProperty {
+	return s.parent.IntProperty(s.fullKey(key), def)
+}
+func (s *subManager) Int64Property(key string, def int64) *Int64Property {
+	return s.parent.Int64Property(s.fullKey(key), def)
+}
+func (s *subManager) FloatProperty(key string, def float64) *FloatProperty {
+	return s.parent.FloatProperty(s.fullKey(key), def)
+}
+func (s *subManager) StringProperty(key string, def string) *StringProperty {
+	return s.parent.StringProperty(s.fullKey(key), def)
+}
+func (s *subManager) BoolProperty(key string, def bool) *BoolProperty {
+	return s.parent.BoolProperty(s.fullKey(key), def)
+}
+func (s *subManager) DurationProperty(key string, def time.Duration) *DurationProperty {
+	return s.parent.DurationProperty(s.fullKey(key), def)
+}
+
+// subHandler 将事件的key转为相对prefix的key
+type subHandler struct {
+	sub     *subManager
+	pattern *regexp.Regexp
+	handler EventHandler
+}
+
+func (h *subHandler) OnPropertyChange(events []*Event) {
+	var subEvents []*Event
+	for _, ev := range events {
+		rel, ok := h.sub.relKey(ev.Key)
+		if !ok || h.pattern == nil || !h.pattern.MatchString(rel) {
+			continue
+		}
+		e := *ev
+		e.Key = rel
+		subEvents = append(subEvents, &e)
+	}
+	if len(subEvents) > 0 {
+		h.handler.OnPropertyChange(subEvents)
+	}
+}
@@ -0,0 +1,67 @@
+package vade
+
+import (
+    "regexp"
+    "sort"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+type testHandler struct {
+    events []*Event
+}
+
+func (h *testHandler) OnPropertyChange(events []*Event) {
+    h.events = append(h.events, events...)
+}
+
+func TestSubManager(t *testing.T) {
+    mgr, err := NewManager()
+    assert.NoError(t, err)
+    mgr.Set("cache.size", 10)
+    mgr.Set("cache.redis.addr", "localhost:6379")
+    mgr.Set("db.addr", "localhost:3306")
+
+    sub := mgr.Sub("cache")
+    v, ok := sub.Get("size")
+    assert.True(t, ok)
+    assert.Equal(t, 10, v)
+    keys := sub.Keys()
+    sort.Strings(keys)
+    assert.Equal(t, []string{"redis.addr", "size"}, keys)
+    assert.Equal(t, map[string]interface{}{"size": 10, "redis.addr": "localhost:6379"}, sub.All())
+
+    sub.SetDefault("ttl", "3s")
+    v, _ = mgr.Get("cache.ttl")
+    assert.Equal(t, "3s", v)
+
+    redis := sub.Sub("redis")
+    v, _ = redis.Get("addr")
+    assert.Equal(t, "localhost:6379", v)
+
+    var cfg struct {
+        Size  int    `yaml:"size"`
+        Redis struct {
+            Addr string `yaml:"addr"`
+        } `yaml:"redis"`
+    }
+    assert.NoError(t, sub.Unmarshal(&cfg))
+    assert.Equal(t, 10, cfg.Size)
+    assert.Equal(t, "localhost:6379", cfg.Redis.Addr)
+}
+
+func TestSubManagerEvents(t *testing.T) {
+    mgr, _ := NewManager()
+    h := &testHandler{}
+    sh := &subHandler{sub: mgr.Sub("cache").(*subManager), handler: h}
+    sh.pattern = regexp.MustCompile("^redis")
+    sh.OnPropertyChange([]*Event{
+        {Action: Updated, Key: "cache.redis.addr"},
+        {Action: Updated, Key: "cache.size"},
+        {Action: Updated, Key: "db.addr"},
+    })
+    if assert.Len(t, h.events, 1) {
+        assert.Equal(t, "redis.addr", h.events[0].Key)
+    }
+}
@@ -157,4 +157,126 @@ func MustTime(key string) time.Time {
     }
     onMustError(key, v, time.Time{})
     return time.Time{}
-}
\ No newline at end of file
+}
+
+// Int64 获取int64值
+func Int64(key string, def int64) int64 {
+    if v, ok := Get(key); ok {
+        if x, err := cast.ToInt64E(v); err == nil {
+            return x
+        }
+    }
+    return def
+}
+
+// MustInt64 获取int64值
+func MustInt64(key string) int64 {
+    var (
+        v interface{}
+        ok bool
+    )
+    if v, ok = Get(key); ok {
+        if x, err := cast.ToInt64E(v); err == nil {
+            return x
+        }
+    }
+    onMustError(key, v, int64(0))
+    return 0
+}
+
+// Uint 获取uint值
+func Uint(key string, def uint) uint {
+    if v, ok := Get(key); ok {
+        if x, err := cast.ToUintE(v); err == nil {
+            return x
+        }
+    }
+    return def
+}
+
+// MustUint 获取uint值
+func MustUint(key string) uint {
+    var (
+        v interface{}
+        ok bool
+    )
+    if v, ok = Get(key); ok {
+        if x, err := cast.ToUintE(v); err == nil {
+            return x
+        }
+    }
+    onMustError(key, v, uint(0))
+    return 0
+}
+
+// Bytes 获取字节数, 支持"64MB", "1GiB"等带单位的值
+func Bytes(key string, def int64) int64 {
+    if v, ok := Get(key); ok {
+        if x, err := toBytes(v); err == nil {
+            return x
+        }
+    }
+    return def
+}
+
+// MustBytes 获取字节数
+func MustBytes(key string) int64 {
+    var (
+        v interface{}
+        ok bool
+    )
+    if v, ok = Get(key); ok {
+        if x, err := toBytes(v); err == nil {
+            return x
+        }
+    }
+    onMustError(key, v, int64(0))
+    return 0
+}
+
+// StringSlice 获取string列表, 字符串的值按逗号分割
+func StringSlice(key string, def []string) []string {
+    items, ok := sliceOf(Get, key)
+    if !ok {
+        return def
+    }
+    values := make([]string, 0, len(items))
+    for _, item := range items {
+        x, err := cast.ToStringE(item)
+        if err != nil {
+            return def
+        }
+        values = append(values, x)
+    }
+    return values
+}
+
+// IntSlice 获取int列表, 字符串的值按逗号分割
+func IntSlice(key string, def []int) []int {
+    items, ok := sliceOf(Get, key)
+    if !ok {
+        return def
+    }
+    values := make([]int, 0, len(items))
+    for _, item := range items {
+        x, err := cast.ToIntE(item)
+        if err != nil {
+            return def
+        }
+        values = append(values, x)
+    }
+    return values
+}
+
+// StringMap 获取key下的所有配置, 字符串的值按"k1=v1,k2=v2"解析
+func StringMap(key string, def map[string]string) map[string]string {
+    if m, ok := stringMapOf(Keys(), Get, key); ok {
+        return m
+    }
+    return def
+}
+
+// Sub 获取prefix下的所有配置, key为相对prefix的key
+func Sub(prefix string) map[string]interface{} {
+    return subOf(Keys(), Get, prefix)
+}
@@ -4,6 +4,7 @@ import (
     "encoding"
     "fmt"
     "reflect"
+    "sort"
     "strconv"
     "strings"
     "time"
@@ -12,6 +13,7 @@ import (
     "github.com/spf13/cast"
 
     "github.com/derry6/vade-go/pkg/structinfo"
+    "github.com/derry6/vade-go/source"
 )
 
 var (
@@ -35,9 +37,101 @@ func WithUnmarshalPrefix(key string) UnmarshalOption {
     }
 }
 
+// WithUnmarshalSparse 保留数组下标的间隔, 缺少的元素为零值, 默认忽略间隔
+func WithUnmarshalSparse() UnmarshalOption {
+    return func(opts *decoder) {
+        opts.sparse = true
+    }
+}
+
+// withUnmarshalOrigin 用于在FieldError中记录值的来源
+func withUnmarshalOrigin(origin func(key string) (string, string, bool)) UnmarshalOption {
+    return func(opts *decoder) {
+        opts.origin = origin
+    }
+}
+
+// withUnmarshalNormalizer 查找子key时使用规范化的key
+func withUnmarshalNormalizer(fn source.KeyNormalizer) UnmarshalOption {
+    return func(opts *decoder) {
+        opts.normalize = fn
+    }
+}
+
+// withUnmarshalOriginalKey 解码map时使用规范化之前的key作为map的key
+func withUnmarshalOriginalKey(fn func(key string) string) UnmarshalOption {
+    return func(opts *decoder) {
+        opts.original = fn
+    }
+}
+
+// WithUnmarshalStrict 前缀下没有被任何字段使用的key作为错误返回, 前缀为空时只检查结构体字段下的key
+func WithUnmarshalStrict() UnmarshalOption {
+    return func(opts *decoder) {
+        opts.strict = true
+    }
+}
+
+// WithUnmarshalUnused 将前缀下没有被使用的key写入unused, 不返回错误
+func WithUnmarshalUnused(unused *[]string) UnmarshalOption {
+    return func(opts *decoder) {
+        opts.unused = unused
+    }
+}
+
+
+// ErrUnknownKey 严格模式下没有被任何字段使用的key
+var ErrUnknownKey = pkgerrs.New("unknown key")
+
+// FieldError 单个字段的解码错误
+type FieldError struct {
+    Key    string       // 配置的key
+    Field  string       // 结构体字段路径, 如Config.DB.Pool.Max
+    Type   reflect.Type // 目标类型
+    Value  interface{}  // 原始值
+    Source string       // 提供该值的source
+    Path   string       // 提供该值的配置集合
+    Err    error
+}
+
+func (e *FieldError) Error() string {
+    var from string
+    if e.Source != "" {
+        from = fmt.Sprintf(" from %s:%s", e.Source, e.Path)
+    }
+    if e.Err == ErrUnknownKey {
+        return fmt.Sprintf("unknown key %s%s", e.Key, from)
+    }
+    msg := fmt.Sprintf("cannot unmarshal %s", e.Key)
+    if e.Type != nil {
+        msg += fmt.Sprintf(" into %s", e.Type)
+    }
+    if e.Field != "" {
+        msg += fmt.Sprintf(" (%s)", e.Field)
+    }
+    if e.Err == nil {
+        return msg + from
+    }
+    return msg + from + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+    return e.Err
+}
 
 type TypeError struct {
     Errors []string
+    Fields []*FieldError
+    // 严格模式下没有被使用的key
+    Unused []string
+}
+
+func newTypeError(fields []*FieldError, unused []string) *TypeError {
+    e := &TypeError{Fields: fields, Unused: unused}
+    for _, f := range fields {
+        e.Errors = append(e.Errors, f.Error())
+    }
+    return e
 }
 
 func (e *TypeError) Error() string {
@@ -45,6 +139,15 @@ func (e *TypeError) Error() string {
         strings.Join(e.Errors, "\n  "))
 }
 
+// As 支持通过errors.As取出第一个FieldError, 全部的错误在Fields中
+func (e *TypeError) As(target interface{}) bool {
+    if p, ok := target.(**FieldError); ok && len(e.Fields) > 0 {
+        *p = e.Fields[0]
+        return true
+    }
+    return false
+}
+
 type noPanicError struct {
     err error
 }
@@ -55,18 +158,78 @@ type Unmarshaler interface {
 }
 
 type decoder struct {
-    errs   []string
-    keys   []string
-    get UnmarshalGet
-    tag    string
-    prefix string
+    errs      []*FieldError
+    keys      []string
+    get       UnmarshalGet
+    tag       string
+    prefix    string
+    strict    bool
+    unused    *[]string
+    hooks     []decodeHook
+    sparse    bool
+    field     string // 当前的结构体字段路径
+    origin    func(key string) (sourceName string, path string, ok bool)
+    normalize source.KeyNormalizer
+    original  func(key string) string
+    used      map[string]bool
 }
 
 func (u *decoder) getValue(key string)(value interface{}, ok bool) {
     if u.get == nil {
         return nil, false
     }
-    return u.get(key)
+    if value, ok = u.get(key); ok && u.used != nil {
+        u.used[u.normalizeKey(key)] = true
+    }
+    return
+}
+
+// markUsed 标记keys已经被使用
+func (u *decoder) markUsed(keys []string) {
+    if u.used == nil {
+        return
+    }
+    for _, k := range keys {
+        u.used[k] = true
+    }
+}
+
+// scopeOf 返回检查未使用key的范围, 前缀为空时只检查结构体字段下的key,
+// 避免环境变量等其他配置被当作未使用的key。返回nil时检查所有的key。
+func (u *decoder) scopeOf(t reflect.Type) []string {
+    if u.prefix != "" {
+        return []string{u.prefix}
+    }
+    for t.Kind() == reflect.Ptr {
+        t = t.Elem()
+    }
+    if t.Kind() != reflect.Struct {
+        return nil
+    }
+    sInfo, err := structinfo.Get(t, u.tag)
+    if err != nil || sInfo.InlineMapIndex != -1 {
+        return nil
+    }
+    roots := make([]string, 0, len(sInfo.FieldsMap))
+    for name := range sInfo.FieldsMap {
+        roots = append(roots, u.normalizeKey(name))
+    }
+    return roots
+}
+
+// unusedKeys 返回scope下没有被读取过的key
+func (u *decoder) unusedKeys(scope []string) (keys []string) {
+    for _, k := range u.keys {
+        in := scope == nil
+        for _, root := range scope {
+            in = in || underKey(k, root)
+        }
+        if in && !u.used[k] {
+            keys = append(keys, k)
+        }
+    }
+    sort.Strings(keys)
+    return
 }
 
 // error handler
@@ -79,14 +242,24 @@ func (u *decoder) handleErr(err *error) {
         }
     }
 }
-func (u *decoder) fail(err error) {
-    panic(noPanicError{err})
-}
 func (u *decoder) failf(format string, args ...interface{}) {
     panic(noPanicError{fmt.Errorf("eacc: "+format, args...)})
 }
 func (u *decoder) addErr(err error, key string, out reflect.Value) {
-    u.errs = append(u.errs, fmt.Sprintf("cannot unmarshal %s into %s: %v", key, out.Type(), err))
+    if err == nil {
+        err = pkgerrs.New("invalid value")
+    }
+    fe := &FieldError{Key: key, Field: u.field, Err: err}
+    if out.IsValid() {
+        fe.Type = out.Type()
+    }
+    if u.get != nil {
+        fe.Value, _ = u.get(key)
+    }
+    if u.origin != nil {
+        fe.Source, fe.Path, _ = u.origin(key)
+    }
+    u.errs = append(u.errs, fe)
 }
 
 func (u *decoder) parseTag(tag string) (string, structinfo.TagOptions) {
@@ -115,44 +288,90 @@ func (u *decoder) findTag(t reflect.StructTag) (tag string, tagOpts structinfo.T
     return
 }
 
-func (u *decoder) arraySize(key string) (n int) {
+// arrayIndexes 返回数组元素的下标, 有长度key时为0到n-1,
+// 否则从key集合中查找所有key[i]形式的下标, 按从小到大排序
+func (u *decoder) arrayIndexes(key string) (indexes []int) {
     if v, ok := u.getValue(key); ok {
+        n := -1
         switch x := v.(type) {
         case int:
-            if x <= 0 {
-                x = 0
-            }
-            return x
+            n = x
         case int64:
-            if x <= 0 {
-                x = 0
-            }
-            return int(x)
+            n = int(x)
+        case []interface{}:
+            // 显式的空列表
+            n = len(x)
+        }
+        for i := 0; i < n; i++ {
+            indexes = append(indexes, i)
+        }
+        if n >= 0 {
+            return
         }
-        return 0
     }
-    for i := 0; i < 32; i++ {
-        pre := key + "[" + strconv.Itoa(i) + "]"
-        got := false
-        for _, k := range u.keys {
-            if pre == k || strings.HasPrefix(k, pre+".") {
-                got = true
-                break
-            }
+    seen := make(map[int]bool)
+    pre := u.normalizeKey(key) + "["
+    for _, k := range u.keys {
+        if !strings.HasPrefix(k, pre) {
+            continue
         }
-        if got {
-            n++
+        rest := k[len(pre):]
+        end := strings.IndexByte(rest, ']')
+        if end <= 0 {
+            continue
+        }
+        if tail := rest[end+1:]; tail != "" && tail[0] != '.' && tail[0] != '[' {
+            continue
+        }
+        i, err := strconv.Atoi(rest[:end])
+        if err != nil || i < 0 || seen[i] {
+            continue
+        }
+        seen[i] = true
+        indexes = append(indexes, i)
+    }
+    sort.Ints(indexes)
+    return
+}
+
+// arrayPositions 返回每个元素在数组中的位置, 非sparse模式下忽略下标的间隔
+func (u *decoder) arrayPositions(indexes []int) (positions []int, size int) {
+    for n, i := range indexes {
+        if u.sparse {
+            positions = append(positions, i)
+            size = i + 1
         } else {
-            return
+            positions = append(positions, n)
+            size = n + 1
         }
     }
     return
 }
+
+func (u *decoder) normalizeKey(key string) string {
+    if u.normalize == nil {
+        return key
+    }
+    return u.normalize(key)
+}
+func (u *decoder) isField(sInfo *structinfo.StructInfo, name string) bool {
+    if _, ok := sInfo.FieldsMap[name]; ok {
+        return true
+    }
+    if u.normalize != nil {
+        for k := range sInfo.FieldsMap {
+            if u.normalize(k) == name {
+                return true
+            }
+        }
+    }
+    return false
+}
 func (u *decoder) childKeysOf(key string) (keys []string) {
     if key == "" {
         return u.keys
     }
-    prefix := key + "."
+    prefix := u.normalizeKey(key) + "."
     for _, k := range u.keys {
         if strings.HasPrefix(k, prefix) {
             keys = append(keys, k)
@@ -162,7 +381,7 @@ func (u *decoder) childKeysOf(key string) (keys []string) {
 }
 func (u *decoder) childName(full string, key string) string {
     if key != "" {
-        key += "."
+        key = u.normalizeKey(key) + "."
     }
     name := strings.TrimPrefix(full, key)
     i := strings.Index(name, ".")
@@ -171,6 +390,34 @@ func (u *decoder) childName(full string, key string) string {
     }
     return name
 }
+
+// mapKeyOf 返回子key在map中的key, 规范化的key使用原始的名称
+func (u *decoder) mapKeyOf(full string, key string, name string) string {
+    if u.original == nil {
+        return name
+    }
+    n := 0
+    if key != "" {
+        n = strings.Count(u.normalizeKey(key), ".") + 1
+    }
+    parts := strings.Split(u.original(full), ".")
+    if len(parts) != strings.Count(full, ".")+1 || n >= len(parts) || u.normalizeKey(parts[n]) != name {
+        return name
+    }
+    return parts[n]
+}
+func (u *decoder) fieldIndex(info structinfo.FieldInfo) []int {
+    if info.Inline == nil {
+        return []int{info.Num}
+    }
+    return info.Inline
+}
+func (u *decoder) fieldPath(parent, name string) string {
+    if parent != "" {
+        return parent + "." + name
+    }
+    return name
+}
 func (u *decoder) mergeKey(prefix, name string) string {
     if prefix != "" {
         return prefix + "." + name
@@ -186,16 +433,25 @@ func (u *decoder) callUnmarshaler(key string, exu Unmarshaler) (good bool) {
         if len(u.errs) > tErrLen {
             issues := u.errs[tErrLen:]
             u.errs = u.errs[:tErrLen]
-            return &TypeError{issues}
+            return newTypeError(issues, nil)
         }
         return nil
     })
     if e, ok := err.(*TypeError); ok {
-        u.errs = append(u.errs, e.Errors...)
+        u.errs = append(u.errs, e.Fields...)
         return false
     }
     if err != nil {
-        u.fail(err)
+        u.addErr(err, key, reflect.ValueOf(exu))
+        return false
+    }
+    return true
+}
+
+func (u *decoder) unmarshalText(key string, tu encoding.TextUnmarshaler, text []byte, out reflect.Value) bool {
+    if err := tu.UnmarshalText(text); err != nil {
+        u.addErr(err, key, out)
+        return false
     }
     return true
 }
@@ -221,15 +477,9 @@ func (u *decoder) indirectPtr(key string, out reflect.Value) (newOut reflect.Val
                 if value, _ := u.getValue(key); value != nil {
                     switch v := value.(type) {
                     case string:
-                        if err := tu.UnmarshalText([]byte(v)); err != nil {
-                            u.fail(err)
-                        }
-                        return out, true, good
+                        return out, true, u.unmarshalText(key, tu, []byte(v), out)
                     case []byte:
-                        if err := tu.UnmarshalText(v); err != nil {
-                            u.fail(err)
-                        }
-                        return out, true, good
+                        return out, true, u.unmarshalText(key, tu, v, out)
                     }
                 }
             }
@@ -240,6 +490,9 @@ func (u *decoder) indirectPtr(key string, out reflect.Value) (newOut reflect.Val
 func (u *decoder) handleBasic(key string, out reflect.Value) bool {
     // finds value from getter
     value, _ := u.getValue(key)
+    return u.setBasic(key, value, out)
+}
+func (u *decoder) setBasic(key string, value interface{}, out reflect.Value) bool {
     if value == nil {
         if out.Kind() == reflect.Map && !out.CanAddr() {
             // 设置map为零值
@@ -266,15 +519,9 @@ func (u *decoder) handleBasic(key string, out reflect.Value) bool {
         if ok {
             switch x := value.(type) {
             case string:
-                if err := tu.UnmarshalText([]byte(x)); err != nil {
-                    u.fail(err)
-                }
-                return true
+                return u.unmarshalText(key, tu, []byte(x), out)
             case []byte:
-                if err := tu.UnmarshalText(x); err != nil {
-                    u.fail(err)
-                }
-                return true
+                return u.unmarshalText(key, tu, x, out)
             }
         }
     }
@@ -289,6 +536,14 @@ func (u *decoder) handleBasic(key string, out reflect.Value) bool {
                 return true
             }
             dstErr = pkgerrs.Wrapf(err, "v = %x", v)
+        default:
+            // 数字等其他类型按cast的规则转换, 整数为纳秒
+            d, err := cast.ToDurationE(v)
+            if err == nil {
+                out.SetInt(int64(d))
+                return true
+            }
+            dstErr = err
         }
         u.addErr(dstErr, key, out)
         return false
@@ -353,6 +608,8 @@ func (u *decoder) handleBasic(key string, out reflect.Value) bool {
         } else {
             dstErr = pkgerrs.New("element type error")
         }
+    default:
+        dstErr = pkgerrs.Errorf("unsupported type %s", out.Type())
     }
     u.addErr(dstErr, key, out)
     return false
@@ -364,37 +621,66 @@ func (u *decoder) handleMap(key string, out reflect.Value) (good bool) {
     if kt.Kind() != reflect.String {
         u.failf("map key type must be string: %#v", kt)
     }
+    children := u.childKeysOf(key)
+    if et.Kind() == reflect.Interface {
+        // 子key都保存在map中
+        u.markUsed(children)
+    }
     if out.IsNil() {
+        // 没有子key并且不是显式的空map时, 保持nil
+        if _, ok := u.getValue(key); !ok && len(children) == 0 && key != "" {
+            return true
+        }
         out.Set(reflect.MakeMap(outt))
     }
-    children := u.childKeysOf(key)
     for _, childKey := range children {
         k := reflect.New(kt).Elem()
         name := u.childName(childKey, key)
-        k.SetString(name)
+        k.SetString(u.mapKeyOf(childKey, key, name))
         full := u.mergeKey(key, name)
         e := reflect.New(et).Elem()
+        parent := u.field
+        u.field = parent + "[" + name + "]"
         if u.unmarshal(full, e) {
             out.SetMapIndex(k, e)
         }
+        u.field = parent
     }
     return true
 }
 func (u *decoder) handleSlice(key string, out reflect.Value) (good bool) {
-    outType := out.Type()
-    elemType := outType.Elem()
-    elemSize := u.arraySize(key)
-    slice := reflect.MakeSlice(outType, elemSize, elemSize)
-    for i := 0; i < elemSize; i++ {
+    indexes := u.arrayIndexes(key)
+    positions, size := u.arrayPositions(indexes)
+    slice := reflect.MakeSlice(out.Type(), size, size)
+    for n, i := range indexes {
         name := key + "[" + strconv.Itoa(i) + "]"
-        v := reflect.New(elemType)
-        if good = u.unmarshal(name, v); !good {
-        }
-        slice.Index(i).Set(v.Elem())
+        parent := u.field
+        u.field = parent + "[" + strconv.Itoa(positions[n]) + "]"
+        u.unmarshal(name, slice.Index(positions[n]))
+        u.field = parent
     }
     out.Set(slice)
     return true
 }
+func (u *decoder) handleArray(key string, out reflect.Value) (good bool) {
+    indexes := u.arrayIndexes(key)
+    positions, _ := u.arrayPositions(indexes)
+    out.Set(reflect.Zero(out.Type()))
+    good = true
+    for n, i := range indexes {
+        name := key + "[" + strconv.Itoa(i) + "]"
+        if positions[n] >= out.Len() {
+            u.addErr(pkgerrs.Errorf("index %d out of range [0, %d)", positions[n], out.Len()), name, out)
+            good = false
+            continue
+        }
+        parent := u.field
+        u.field = parent + "[" + strconv.Itoa(positions[n]) + "]"
+        u.unmarshal(name, out.Index(positions[n]))
+        u.field = parent
+    }
+    return good
+}
 
 func (u *decoder) handleStruct(key string, out reflect.Value) (good bool) {
     sInfo, err := structinfo.Get(out.Type(), u.tag)
@@ -411,7 +697,10 @@ func (u *decoder) handleStruct(key string, out reflect.Value) (good bool) {
             field = out.FieldByIndex(info.Inline)
         }
         fullName := u.mergeKey(key, name)
+        parent := u.field
+        u.field = u.fieldPath(parent, out.Type().FieldByIndex(u.fieldIndex(info)).Name)
         u.unmarshal(fullName, field)
+        u.field = parent
     }
     // handle inlined
     if sInfo.InlineMapIndex == -1 {
@@ -423,28 +712,37 @@ func (u *decoder) handleStruct(key string, out reflect.Value) (good bool) {
     inlineMap = out.Field(sInfo.InlineMapIndex)
     inlineMap.Set(reflect.New(inlineMap.Type()).Elem())
     elemType = inlineMap.Type().Elem()
+    if elemType.Kind() == reflect.Interface {
+        u.markUsed(children)
+    }
 
     if inlineMap.IsNil() {
         inlineMap.Set(reflect.MakeMap(inlineMap.Type()))
     }
     // getter all fields not decoded to inlined field
-    for _, name := range children {
-        name = u.childName(name, key)
+    for _, child := range children {
+        name := u.childName(child, key)
         if len(name) == 0 {
             continue
         }
-        if _, ok := sInfo.FieldsMap[name]; ok {
+        if u.isField(sInfo, name) {
             continue
         }
         elemKey := u.mergeKey(key, name)
         value := reflect.New(elemType).Elem()
+        parent := u.field
+        u.field = parent + "[" + name + "]"
         u.unmarshal(elemKey, value)
-        inlineMap.SetMapIndex(reflect.ValueOf(name), value)
+        u.field = parent
+        inlineMap.SetMapIndex(reflect.ValueOf(u.mapKeyOf(child, key, name)), value)
     }
     return true
 }
 
 func (u *decoder) unmarshal(key string, out reflect.Value) (good bool) {
+    if done, good := u.applyHooks(key, out); done {
+        return good
+    }
     out, done, good := u.indirectPtr(key, out)
     if done {
         return good
@@ -454,6 +752,8 @@ func (u *decoder) unmarshal(key string, out reflect.Value) (good bool) {
         good = u.handleMap(key, out)
     case reflect.Slice:
         good = u.handleSlice(key, out)
+    case reflect.Array:
+        good = u.handleArray(key, out)
     case reflect.Struct:
         good = u.handleStruct(key, out)
     default:
@@ -475,14 +775,34 @@ func unmarshal(get UnmarshalGet, keys []string, out interface{}, opts ...Unmarsh
     if len(d.prefix) > 0 && d.prefix[n] == '.' {
         d.prefix = d.prefix[:n]
     }
+    d.prefix = d.normalizeKey(d.prefix)
+    if d.strict || d.unused != nil {
+        d.used = make(map[string]bool)
+    }
+    d.field = v.Type().Name()
     defer d.handleErr(&err)
     d.unmarshal(d.prefix, v)
+    var unused []string
+    if d.used != nil {
+        unused = d.unusedKeys(d.scopeOf(v.Type()))
+        if d.unused != nil {
+            *d.unused = unused
+        }
+    }
+    if d.strict {
+        for _, k := range unused {
+            d.field = ""
+            d.addErr(ErrUnknownKey, k, zeroValue)
+        }
+    } else {
+        unused = nil
+    }
     if len(d.errs) > 0 {
-        return &TypeError{d.errs}
+        return newTypeError(d.errs, unused)
     }
     return nil
 }
 
 func Unmarshal(out interface{}, opts ...UnmarshalOption) error {
-    return unmarshal(_mgr.Get, _mgr.Keys(), out, opts...)
+    return _mgr.Unmarshal(out, opts...)
 }
\ No newline at end of file
@@ -1,6 +1,10 @@
 package vade
 
 import (
+    "errors"
+    "net"
+    "reflect"
+    "strconv"
     "testing"
     "time"
 )
@@ -48,3 +52,210 @@ func TestUnmarshalBasic(t *testing.T) {
         t.Errorf("Unmarshal error: c.d=%v, expect 3s", v.D)
     }
 }
+
+func TestUnmarshalEmptyMap(t *testing.T) {
+    store := newTestUnmarshalGetter()
+    store.Set("empty", map[string]interface{}{})
+    store.Set("list", []interface{}{})
+    type Value struct {
+        List   []string          `yaml:"list"`
+        Empty  map[string]string `yaml:"empty"`
+        Absent map[string]string `yaml:"absent"`
+    }
+    var v Value
+    if err := unmarshal(store.Get, store.Keys(), &v); err != nil {
+        t.Error(err)
+    }
+    if v.Empty == nil {
+        t.Errorf("Unmarshal error: empty map is nil")
+    }
+    if v.List == nil || len(v.List) != 0 {
+        t.Errorf("Unmarshal error: list = %#v, expect empty", v.List)
+    }
+    if v.Absent != nil {
+        t.Errorf("Unmarshal error: absent map is %v, expect nil", v.Absent)
+    }
+}
+
+func TestUnmarshalStrict(t *testing.T) {
+    store := newTestUnmarshalGetter()
+    store.Set("db.maxConn", 10)
+    store.Set("db.maxConns", 20)
+    store.Set("db.hosts", 1)
+    store.Set("db.hosts[0]", "a")
+    store.Set("log.level", "debug")
+    type DB struct {
+        MaxConn int      `yaml:"maxConn"`
+        Hosts   []string `yaml:"hosts"`
+    }
+    var v DB
+    var unused []string
+    opts := []UnmarshalOption{WithUnmarshalPrefix("db"), WithUnmarshalUnused(&unused)}
+    if err := unmarshal(store.Get, store.Keys(), &v, opts...); err != nil {
+        t.Fatal(err)
+    }
+    if len(unused) != 1 || unused[0] != "db.maxConns" {
+        t.Errorf("unused keys = %v, expect [db.maxConns]", unused)
+    }
+    opts = append(opts, WithUnmarshalStrict())
+    err := unmarshal(store.Get, store.Keys(), &v, opts...)
+    tErr, ok := err.(*TypeError)
+    if !ok {
+        t.Fatalf("Unmarshal error: %v, expect TypeError", err)
+    }
+    if len(tErr.Unused) != 1 || tErr.Unused[0] != "db.maxConns" {
+        t.Errorf("TypeError.Unused = %v, expect [db.maxConns]", tErr.Unused)
+    }
+    if v.MaxConn != 10 || len(v.Hosts) != 1 {
+        t.Errorf("Unmarshal error: %+v", v)
+    }
+}
+
+func TestUnmarshalStrictScope(t *testing.T) {
+    store := newTestUnmarshalGetter()
+    store.Set("PATH", "/usr/bin")
+    store.Set("log.level", "debug")
+    store.Set("db.maxConn", 10)
+    store.Set("db.maxConns", 20)
+    store.Set("extra.a.b", 1)
+    store.Set("extra.c", "x")
+    type DB struct {
+        MaxConn int `yaml:"maxConn"`
+    }
+    var v struct {
+        DB    DB                     `yaml:"db"`
+        Extra map[string]interface{} `yaml:"extra"`
+    }
+    // 前缀为空时只检查字段下的key, map中的子key都被使用
+    err := unmarshal(store.Get, store.Keys(), &v, WithUnmarshalStrict())
+    tErr, ok := err.(*TypeError)
+    if !ok {
+        t.Fatalf("Unmarshal error: %v, expect TypeError", err)
+    }
+    if len(tErr.Unused) != 1 || tErr.Unused[0] != "db.maxConns" {
+        t.Errorf("TypeError.Unused = %v, expect [db.maxConns]", tErr.Unused)
+    }
+}
+
+func TestUnmarshalArrays(t *testing.T) {
+    store := newTestUnmarshalGetter()
+    for i := 0; i < 40; i++ {
+        store.Set("large["+strconv.Itoa(i)+"]", i)
+    }
+    store.Set("gaps[0]", "a")
+    store.Set("gaps[3]", "b")
+    store.Set("fixed[0]", 1)
+    store.Set("fixed[1]", 2)
+    store.Set("items[0].name", "x")
+    store.Set("items[2].name", "y")
+    type Item struct {
+        Name string `yaml:"name"`
+    }
+    type Value struct {
+        Large []int    `yaml:"large"`
+        Gaps  []string `yaml:"gaps"`
+        Fixed [4]int   `yaml:"fixed"`
+        Items []*Item  `yaml:"items"`
+    }
+    var v Value
+    if err := unmarshal(store.Get, store.Keys(), &v); err != nil {
+        t.Fatal(err)
+    }
+    if len(v.Large) != 40 || v.Large[39] != 39 {
+        t.Errorf("large = %v", v.Large)
+    }
+    if len(v.Gaps) != 2 || v.Gaps[1] != "b" {
+        t.Errorf("gaps = %v, expect [a b]", v.Gaps)
+    }
+    if v.Fixed != [4]int{1, 2, 0, 0} {
+        t.Errorf("fixed = %v", v.Fixed)
+    }
+    if len(v.Items) != 2 || v.Items[1].Name != "y" {
+        t.Errorf("items = %v", v.Items)
+    }
+
+    v = Value{}
+    if err := unmarshal(store.Get, store.Keys(), &v, WithUnmarshalSparse()); err != nil {
+        t.Fatal(err)
+    }
+    if len(v.Gaps) != 4 || v.Gaps[0] != "a" || v.Gaps[3] != "b" {
+        t.Errorf("sparse gaps = %q", v.Gaps)
+    }
+    if len(v.Items) != 3 || v.Items[1] != nil || v.Items[2].Name != "y" {
+        t.Errorf("sparse items = %v", v.Items)
+    }
+
+    store.Set("fixed[4]", 5)
+    if err := unmarshal(store.Get, store.Keys(), &v, WithUnmarshalSparse()); err == nil {
+        t.Errorf("Unmarshal error: expect index out of range")
+    }
+}
+
+func TestUnmarshalFieldError(t *testing.T) {
+    store := newTestUnmarshalGetter()
+    store.Set("db.pool.max", "many")
+    store.Set("db.addr", "localhost")
+    store.Set("db.hosts[0]", "x")
+    type Pool struct {
+        Max int `yaml:"max"`
+    }
+    type DB struct {
+        Pool  Pool     `yaml:"pool"`
+        Addr  string   `yaml:"addr"`
+        Hosts []net.IP `yaml:"hosts"`
+    }
+    type Config struct {
+        DB DB `yaml:"db"`
+    }
+    var v Config
+    origin := func(key string) (string, string, bool) { return "file", "app.yaml", true }
+    err := unmarshal(store.Get, store.Keys(), &v, withUnmarshalOrigin(origin))
+    tErr, ok := err.(*TypeError)
+    if !ok {
+        t.Fatalf("Unmarshal error: %v, expect TypeError", err)
+    }
+    if len(tErr.Fields) != 2 {
+        t.Fatalf("field errors = %v, expect 2", tErr.Fields)
+    }
+    var fe *FieldError
+    if !errors.As(err, &fe) {
+        t.Fatalf("errors.As failed")
+    }
+    fields := map[string]*FieldError{}
+    for _, f := range tErr.Fields {
+        fields[f.Key] = f
+    }
+    if f := fields["db.pool.max"]; f == nil || f.Field != "Config.DB.Pool.Max" || f.Value != "many" ||
+        f.Source != "file" || f.Path != "app.yaml" || f.Type.Kind() != reflect.Int {
+        t.Errorf("field error = %+v", f)
+    }
+    if f := fields["db.hosts[0]"]; f == nil || f.Field != "Config.DB.Hosts[0]" {
+        t.Errorf("field error = %+v", f)
+    }
+    if v.DB.Addr != "localhost" {
+        t.Errorf("addr = %q, expect localhost", v.DB.Addr)
+    }
+}
+
+func TestUnmarshalDurationNumber(t *testing.T) {
+    store := newTestUnmarshalGetter()
+    store.Set("timeout", 5)
+    store.Set("interval", true)
+    type Config struct {
+        Timeout  time.Duration `yaml:"timeout"`
+        Interval time.Duration `yaml:"interval"`
+    }
+    var v Config
+    err := unmarshal(store.Get, store.Keys(), &v)
+    if v.Timeout != 5 {
+        t.Errorf("timeout = %v, expect 5ns", v.Timeout)
+    }
+    tErr, ok := err.(*TypeError)
+    if !ok || len(tErr.Fields) != 1 || tErr.Fields[0].Key != "interval" || tErr.Fields[0].Err == nil {
+        t.Fatalf("Unmarshal error: %v", err)
+    }
+    _ = tErr.Error()
+    if s := (&FieldError{Key: "k"}).Error(); s != "cannot unmarshal k" {
+        t.Errorf("error = %q", s)
+    }
+}
@@ -1,43 +1,22 @@
 package vade
 
 import (
-    "os"
-    "path/filepath"
+    "math"
+    "reflect"
     "strconv"
     "strings"
     "time"
 
     "github.com/go-errors/errors"
+    "github.com/spf13/cast"
 
-    "github.com/derry6/vade-go/pkg/log"
+    "github.com/derry6/vade-go/pkg/structinfo"
 )
 
 const (
-    maxFileSize       = 1 * 1024 * 1024
     nanoSecondsPerDay = 24 /*h*/ * 60 /*m*/ * 60 /*s*/ * 1e9
 )
 
-func filesInDir(root string, cbFn func(f string) error) (err error) {
-    return filepath.Walk(root, func(path string, info os.FileInfo, iErr error) error {
-        log.Get().Infof("walk: %s", path)
-        if iErr != nil {
-            return iErr
-        }
-        if info.IsDir() {
-            if path == root {
-                return nil
-            }
-            return filepath.SkipDir
-        }
-        if info.Size() < maxFileSize {
-            if iErr = cbFn(path); iErr != nil {
-                return iErr
-            }
-        }
-        return nil
-    })
-}
-
 // toDuration 支持带d的duration解析
 func toDuration(s string) (v time.Duration, err error) {
     var d int64
@@ -63,3 +42,210 @@ func toDuration(s string) (v time.Duration, err error) {
     }
     return time.Duration(int64(v) + d*nanoSecondsPerDay), nil
 }
+
+type getFunc func(key string) (value interface{}, ok bool)
+
+// sliceOf 获取列表: 根据长度key重组展开的列表, 来自env或者flag的字符串按逗号分割
+func sliceOf(get getFunc, key string) (items []interface{}, ok bool) {
+    v, ok := get(key)
+    if !ok {
+        for i := 0; ; i++ {
+            item, found := get(key + "[" + strconv.Itoa(i) + "]")
+            if !found {
+                break
+            }
+            items = append(items, item)
+        }
+        return items, len(items) > 0
+    }
+    switch x := v.(type) {
+    case string:
+        return splitList(x), true
+    case []interface{}:
+        return x, true
+    case []string:
+        for _, s := range x {
+            items = append(items, s)
+        }
+        return items, true
+    case int, int64:
+        // 展开的列表, key的值为列表长度
+        n := cast.ToInt(x)
+        if n == 0 {
+            return []interface{}{}, true
+        }
+        if _, found := get(key + "[0]"); !found {
+            return []interface{}{v}, true
+        }
+        for i := 0; i < n; i++ {
+            item, _ := get(key + "[" + strconv.Itoa(i) + "]")
+            items = append(items, item)
+        }
+        return items, true
+    }
+    if items, err := cast.ToSliceE(v); err == nil {
+        return items, true
+    }
+    return []interface{}{v}, true
+}
+
+func splitList(s string) []interface{} {
+    items := []interface{}{}
+    if strings.TrimSpace(s) == "" {
+        return items
+    }
+    for _, item := range strings.Split(s, ",") {
+        items = append(items, strings.TrimSpace(item))
+    }
+    return items
+}
+
+// subOf 获取prefix下的所有配置, key为相对prefix的key
+func subOf(keys []string, get getFunc, prefix string) map[string]interface{} {
+    values := make(map[string]interface{})
+    prefix = strings.TrimSuffix(prefix, ".")
+    for _, key := range keys {
+        name := key
+        if prefix != "" {
+            if !strings.HasPrefix(key, prefix+".") {
+                continue
+            }
+            name = key[len(prefix)+1:]
+        }
+        if v, ok := get(key); ok {
+            values[name] = v
+        }
+    }
+    return values
+}
+
+// stringMapOf 获取prefix下的配置, 来自env或者flag的字符串按"k=v,k2=v2"解析
+func stringMapOf(keys []string, get getFunc, key string) (m map[string]string, ok bool) {
+    sub := subOf(keys, get, key)
+    if len(sub) == 0 {
+        v, found := get(key)
+        if !found {
+            return nil, false
+        }
+        s, isStr := v.(string)
+        if !isStr {
+            if x, err := cast.ToStringMapStringE(v); err == nil {
+                return x, true
+            }
+            return nil, false
+        }
+        m = map[string]string{}
+        for _, item := range splitList(s) {
+            kv := strings.SplitN(item.(string), "=", 2)
+            if len(kv) != 2 {
+                return nil, false
+            }
+            m[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+        }
+        return m, true
+    }
+    m = make(map[string]string, len(sub))
+    for k, v := range sub {
+        s, err := cast.ToStringE(v)
+        if err != nil {
+            return nil, false
+        }
+        m[k] = s
+    }
+    return m, true
+}
+
+var byteUnits = []struct {
+    suffix string
+    size   int64
+}{
+    {"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30}, {"TIB", 1 << 40},
+    {"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"TB", 1 << 40},
+    {"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}, {"T", 1 << 40},
+    {"B", 1},
+}
+
+// toBytes 解析字节数, 支持K,KB,KiB等后缀, 都按1024计算, 如"64MB", "1.5GiB", 不能为负数或者超过int64
+func toBytes(v interface{}) (int64, error) {
+    s, ok := v.(string)
+    if !ok {
+        n, err := cast.ToInt64E(v)
+        if err == nil && n < 0 {
+            return 0, errors.Errorf("invalid bytes: %v", v)
+        }
+        return n, err
+    }
+    s = strings.ToUpper(strings.TrimSpace(s))
+    size := int64(1)
+    for _, unit := range byteUnits {
+        if strings.HasSuffix(s, unit.suffix) {
+            s, size = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix)), unit.size
+            break
+        }
+    }
+    if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+        if n < 0 || n > math.MaxInt64/size {
+            return 0, errors.Errorf("invalid bytes: %q", v)
+        }
+        return n * size, nil
+    }
+    f, err := strconv.ParseFloat(s, 64)
+    // float64(math.MaxInt64)为2^63, 已经超出int64
+    if err != nil || !(f >= 0) || f*float64(size) >= float64(math.MaxInt64) {
+        return 0, errors.Errorf("invalid bytes: %q", v)
+    }
+    return int64(f * float64(size)), nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// walkFunc 处理结构体的成员, key为prefix加上成员的key
+type walkFunc func(key string, sf reflect.StructField, fv reflect.Value) error
+
+// walkStruct 遍历结构体的成员, 嵌套的结构体会递归处理, 空指针使用零值
+func walkStruct(prefix string, v interface{}, fn walkFunc) error {
+    rv := indirectValue(reflect.ValueOf(v))
+    if rv.Kind() != reflect.Struct {
+        return errors.Errorf("vade: require a struct, but %v", rv.Type())
+    }
+    return walkFields(strings.TrimSuffix(prefix, "."), rv, fn)
+}
+
+func indirectValue(rv reflect.Value) reflect.Value {
+    for rv.Kind() == reflect.Ptr {
+        if rv.IsNil() {
+            rv = reflect.Zero(rv.Type().Elem())
+        } else {
+            rv = rv.Elem()
+        }
+    }
+    return rv
+}
+
+func walkFields(prefix string, rv reflect.Value, fn walkFunc) error {
+    sInfo, err := structinfo.Get(rv.Type(), "")
+    if err != nil {
+        return err
+    }
+    for _, info := range sInfo.FieldsList {
+        index := info.Inline
+        if index == nil {
+            index = []int{info.Num}
+        }
+        key := info.Key
+        if prefix != "" {
+            key = prefix + "." + key
+        }
+        sf := rv.Type().FieldByIndex(index)
+        fv := indirectValue(rv.FieldByIndex(index))
+        if fv.Kind() == reflect.Struct && fv.Type() != timeType {
+            err = walkFields(key, fv, fn)
+        } else {
+            err = fn(key, sf, fv)
+        }
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
@@ -0,0 +1,81 @@
+package vade
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestSliceOf(t *testing.T) {
+    store := newTestUnmarshalGetter()
+    store.Set("a", 2)
+    store.Set("a[0]", "x")
+    store.Set("a[1]", "y")
+    store.Set("b", "x, y ,z")
+    store.Set("c[0]", 1)
+    store.Set("port", 8080)
+    store.Set("empty", "")
+    var tests = []struct {
+        key    string
+        expect []interface{}
+        ok     bool
+    }{
+        {"a", []interface{}{"x", "y"}, true},
+        {"b", []interface{}{"x", "y", "z"}, true},
+        {"c", []interface{}{1}, true},
+        {"port", []interface{}{8080}, true},
+        {"empty", []interface{}{}, true},
+        {"none", nil, false},
+    }
+    for _, tt := range tests {
+        items, ok := sliceOf(store.Get, tt.key)
+        if ok != tt.ok || !reflect.DeepEqual(items, tt.expect) {
+            t.Errorf("sliceOf(%q) = %v, %v, expect %v, %v", tt.key, items, ok, tt.expect, tt.ok)
+        }
+    }
+}
+
+func TestStringMapOf(t *testing.T) {
+    store := newTestUnmarshalGetter()
+    store.Set("labels.app", "web")
+    store.Set("labels.tier.name", "front")
+    store.Set("env", "a=1, b=2")
+    m, ok := stringMapOf(store.Keys(), store.Get, "labels")
+    if !ok || !reflect.DeepEqual(m, map[string]string{"app": "web", "tier.name": "front"}) {
+        t.Errorf("stringMapOf(labels) = %v", m)
+    }
+    m, ok = stringMapOf(store.Keys(), store.Get, "env")
+    if !ok || !reflect.DeepEqual(m, map[string]string{"a": "1", "b": "2"}) {
+        t.Errorf("stringMapOf(env) = %v", m)
+    }
+}
+
+func TestToBytes(t *testing.T) {
+    var tests = []struct {
+        value  interface{}
+        expect int64
+        err    bool
+    }{
+        {1024, 1024, false},
+        {"512", 512, false},
+        {"64MB", 64 << 20, false},
+        {"1.5GiB", 3 << 29, false},
+        {"4k", 4096, false},
+        {"10B", 10, false},
+        {"abc", 0, true},
+        {"10000000000GB", 0, true},
+        {"8388608TB", 0, true},
+        {"8388607TB", 8388607 << 40, false},
+        {"1e30KB", 0, true},
+        {"-1KB", 0, true},
+        {"-1.5KB", 0, true},
+        {"-1", 0, true},
+        {-1, 0, true},
+        {"NaN", 0, true},
+    }
+    for _, tt := range tests {
+        n, err := toBytes(tt.value)
+        if (err != nil) != tt.err || n != tt.expect {
+            t.Errorf("toBytes(%v) = %d, %v, expect %d", tt.value, n, err, tt.expect)
+        }
+    }
+}